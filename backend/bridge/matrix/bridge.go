@@ -0,0 +1,225 @@
+package matrix
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// linkCommandPrefix is the chat command a user sends to their puppet's
+// room (or any room the bridge bot can see) to claim their MXID with a
+// one-time code minted by the /api/bridge/matrix/link endpoint.
+const linkCommandPrefix = "!link "
+
+// TransactionEvent is the subset of a Matrix room event the bridge cares
+// about, as delivered by the homeserver's AS push transactions.
+type TransactionEvent struct {
+	Type    string `json:"type"`
+	RoomID  string `json:"room_id"`
+	Sender  string `json:"sender"`
+	EventID string `json:"event_id"`
+	Content struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"content"`
+}
+
+// Bridge translates between MindBridge's socket layer and a Matrix
+// homeserver: it mirrors circle activity out to Matrix via puppet users,
+// and turns inbound Matrix events back into MindBridge messages.
+type Bridge struct {
+	cfg    Config
+	client *Client
+	db     *db.PrismaClient
+}
+
+func NewBridge(cfg Config, client *Client, prismaClient *db.PrismaClient) *Bridge {
+	return &Bridge{cfg: cfg, client: client, db: prismaClient}
+}
+
+// OnNewMessage mirrors a circle chat message into its bridged Matrix room.
+func (b *Bridge) OnNewMessage(ctx context.Context, circleID, senderID, senderName, content string) {
+	if !b.cfg.Enabled() {
+		return
+	}
+
+	if err := b.ensurePuppet(ctx, senderID, senderName); err != nil {
+		log.Printf("matrix bridge: %v", err)
+		return
+	}
+
+	mxid := b.cfg.PuppetMXID(senderID)
+	if err := b.client.SendRoomMessage(b.cfg.RoomAlias(circleID), mxid, "m.text", content); err != nil {
+		log.Printf("matrix bridge: failed to mirror message for circle %s: %v", circleID, err)
+	}
+}
+
+// OnMessageRead mirrors a read receipt into the bridged room.
+func (b *Bridge) OnMessageRead(circleID, messageID, userID string) {
+	if !b.cfg.Enabled() {
+		return
+	}
+
+	mxid := b.cfg.PuppetMXID(userID)
+	if err := b.client.SendReadReceipt(b.cfg.RoomAlias(circleID), messageID, mxid); err != nil {
+		log.Printf("matrix bridge: failed to mirror receipt for circle %s: %v", circleID, err)
+	}
+}
+
+// OnTyping mirrors a typing indicator into the bridged room.
+func (b *Bridge) OnTyping(circleID, userID string, typing bool) {
+	if !b.cfg.Enabled() {
+		return
+	}
+
+	mxid := b.cfg.PuppetMXID(userID)
+	if err := b.client.SendTyping(b.cfg.RoomAlias(circleID), mxid, typing); err != nil {
+		log.Printf("matrix bridge: failed to mirror typing for circle %s: %v", circleID, err)
+	}
+}
+
+// ensurePuppet registers the puppet Matrix user for a MindBridge user the
+// first time it's needed and records the mapping, so later sends skip the
+// registration round trip.
+func (b *Bridge) ensurePuppet(ctx context.Context, userID, displayName string) error {
+	existing, err := b.db.MatrixUserMapping.FindFirst(
+		db.MatrixUserMapping.UserID.Equals(userID),
+	).Exec(ctx)
+	if err == nil && existing != nil {
+		return nil
+	}
+
+	if err := b.client.EnsurePuppet(userID, displayName); err != nil {
+		return err
+	}
+
+	_, err = b.db.MatrixUserMapping.CreateOne(
+		db.MatrixUserMapping.MxID.Set(b.cfg.PuppetMXID(userID)),
+		db.MatrixUserMapping.User.Link(db.User.ID.Equals(userID)),
+	).Exec(ctx)
+	return err
+}
+
+// HandleTransaction processes one AS push transaction's worth of Matrix
+// room events, mirroring circle-room messages back into MindBridge and
+// honoring any pending !link claims.
+func (b *Bridge) HandleTransaction(ctx context.Context, events []TransactionEvent) {
+	for _, event := range events {
+		if event.Type != "m.room.message" {
+			continue
+		}
+
+		if strings.HasPrefix(event.Sender, "@"+b.cfg.UserPrefix) {
+			// Our own puppet echoing its message back - ignore.
+			continue
+		}
+
+		body := strings.TrimSpace(event.Content.Body)
+		if strings.HasPrefix(body, linkCommandPrefix) {
+			b.claimLink(ctx, event.Sender, strings.TrimSpace(strings.TrimPrefix(body, linkCommandPrefix)))
+			continue
+		}
+
+		b.relayInbound(ctx, event)
+	}
+}
+
+func (b *Bridge) relayInbound(ctx context.Context, event TransactionEvent) {
+	circleID, ok := b.circleIDFromRoom(ctx, event.RoomID)
+	if !ok {
+		return
+	}
+
+	senderID, err := b.resolveSender(ctx, event.Sender)
+	if err != nil {
+		log.Printf("matrix bridge: unlinked sender %s tried to message circle %s", event.Sender, circleID)
+		return
+	}
+
+	if _, err := b.db.Message.CreateOne(
+		db.Message.Circle.Link(db.Circle.ID.Equals(circleID)),
+		db.Message.Sender.Link(db.User.ID.Equals(senderID)),
+		db.Message.Content.Set(event.Content.Body),
+	).Exec(ctx); err != nil {
+		log.Printf("matrix bridge: failed to relay message from %s into circle %s: %v", event.Sender, circleID, err)
+	}
+}
+
+// circleIDFromRoom resolves a Matrix room ID back to a circle ID by
+// looking up the room's published aliases and matching our own prefix,
+// since AS transactions identify rooms by ID rather than alias.
+func (b *Bridge) circleIDFromRoom(ctx context.Context, roomID string) (string, bool) {
+	aliases, err := b.client.RoomAliases(roomID)
+	if err != nil {
+		log.Printf("matrix bridge: failed to resolve aliases for room %s: %v", roomID, err)
+		return "", false
+	}
+
+	prefix := "#" + b.cfg.AliasPrefix
+	suffix := ":" + b.cfg.ServerName
+	for _, alias := range aliases {
+		if strings.HasPrefix(alias, prefix) && strings.HasSuffix(alias, suffix) {
+			return strings.TrimSuffix(strings.TrimPrefix(alias, prefix), suffix), true
+		}
+	}
+	return "", false
+}
+
+func (b *Bridge) resolveSender(ctx context.Context, mxid string) (string, error) {
+	mapping, err := b.db.MatrixUserMapping.FindUnique(
+		db.MatrixUserMapping.MxID.Equals(mxid),
+	).Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+	return mapping.UserID, nil
+}
+
+func (b *Bridge) claimLink(ctx context.Context, mxid, code string) {
+	if code == "" {
+		return
+	}
+
+	link, err := b.db.MatrixLinkCode.FindUnique(
+		db.MatrixLinkCode.Code.Equals(code),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("matrix bridge: unknown link code from %s", mxid)
+		return
+	}
+
+	if link.Used {
+		log.Printf("matrix bridge: %s tried to reuse a spent link code", mxid)
+		return
+	}
+
+	if time.Now().UTC().After(time.Time(link.ExpiresAt)) {
+		log.Printf("matrix bridge: %s tried to use an expired link code", mxid)
+		return
+	}
+
+	if _, err := b.db.MatrixUserMapping.UpsertOne(
+		db.MatrixUserMapping.MxID.Equals(mxid),
+	).Create(
+		db.MatrixUserMapping.MxID.Set(mxid),
+		db.MatrixUserMapping.User.Link(db.User.ID.Equals(link.UserID)),
+	).Update(
+		db.MatrixUserMapping.User.Link(db.User.ID.Equals(link.UserID)),
+	).Exec(ctx); err != nil {
+		log.Printf("matrix bridge: failed to link %s to user %s: %v", mxid, link.UserID, err)
+		return
+	}
+
+	if _, err := b.db.MatrixLinkCode.FindUnique(
+		db.MatrixLinkCode.Code.Equals(code),
+	).Update(
+		db.MatrixLinkCode.Used.Set(true),
+	).Exec(ctx); err != nil {
+		log.Printf("matrix bridge: failed to mark link code spent: %v", err)
+	}
+
+	log.Printf("matrix bridge: linked %s to MindBridge user %s", mxid, link.UserID)
+}
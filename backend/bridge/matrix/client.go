@@ -0,0 +1,169 @@
+package matrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Client is a minimal Application Service client for the homeserver
+// Client-Server API, authenticated with the AS token and acting on behalf
+// of puppet users via the `user_id` query parameter.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	return &Client{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// EnsurePuppet registers the puppet user for a MindBridge user if it
+// doesn't already exist on the homeserver. Application Services may
+// register namespaced users without their own password.
+func (c *Client) EnsurePuppet(userID, displayName string) error {
+	localpart := c.cfg.UserPrefix + userID
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":     "m.login.application_service",
+		"username": localpart,
+	})
+	if err != nil {
+		return fmt.Errorf("matrix: failed to marshal register request: %w", err)
+	}
+
+	resp, err := c.asRequest(http.MethodPost, "/_matrix/client/v3/register", "", body)
+	if err != nil {
+		return fmt.Errorf("matrix: failed to register puppet %s: %w", localpart, err)
+	}
+	defer resp.Body.Close()
+
+	// 400 M_USER_IN_USE means the puppet already exists - not an error.
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("matrix: register puppet %s returned status %d", localpart, resp.StatusCode)
+	}
+
+	mxid := c.cfg.PuppetMXID(userID)
+	return c.setDisplayName(mxid, displayName)
+}
+
+func (c *Client) setDisplayName(mxid, displayName string) error {
+	body, err := json.Marshal(map[string]string{"displayname": displayName})
+	if err != nil {
+		return fmt.Errorf("matrix: failed to marshal displayname request: %w", err)
+	}
+
+	path := fmt.Sprintf("/_matrix/client/v3/profile/%s/displayname", url.PathEscape(mxid))
+	resp, err := c.asRequest(http.MethodPut, path, mxid, body)
+	if err != nil {
+		return fmt.Errorf("matrix: failed to set displayname for %s: %w", mxid, err)
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// SendRoomMessage posts an m.room.message event into roomID as mxid.
+func (c *Client) SendRoomMessage(roomID, mxid, msgtype, body string) error {
+	payload, err := json.Marshal(map[string]string{
+		"msgtype": msgtype,
+		"body":    body,
+	})
+	if err != nil {
+		return fmt.Errorf("matrix: failed to marshal message event: %w", err)
+	}
+
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message", url.PathEscape(roomID))
+	resp, err := c.asRequest(http.MethodPost, path, mxid, payload)
+	if err != nil {
+		return fmt.Errorf("matrix: failed to send message to %s: %w", roomID, err)
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// SendReadReceipt posts an m.receipt event for eventID as mxid.
+func (c *Client) SendReadReceipt(roomID, eventID, mxid string) error {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/receipt/m.read/%s", url.PathEscape(roomID), url.PathEscape(eventID))
+	resp, err := c.asRequest(http.MethodPost, path, mxid, []byte("{}"))
+	if err != nil {
+		return fmt.Errorf("matrix: failed to send receipt in %s: %w", roomID, err)
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// SendTyping sets or clears the typing indicator for mxid in roomID.
+func (c *Client) SendTyping(roomID, mxid string, typing bool) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"typing":  typing,
+		"timeout": 15000,
+	})
+	if err != nil {
+		return fmt.Errorf("matrix: failed to marshal typing event: %w", err)
+	}
+
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/typing/%s", url.PathEscape(roomID), url.PathEscape(mxid))
+	resp, err := c.asRequest(http.MethodPut, path, mxid, payload)
+	if err != nil {
+		return fmt.Errorf("matrix: failed to send typing in %s: %w", roomID, err)
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// RoomAliases returns the published alt aliases for roomID, used to map an
+// inbound transaction's room ID back to the circle it bridges.
+func (c *Client) RoomAliases(roomID string) ([]string, error) {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/aliases", url.PathEscape(roomID))
+	resp, err := c.asRequest(http.MethodGet, path, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("matrix: failed to list aliases for %s: %w", roomID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("matrix: list aliases for %s returned status %d", roomID, resp.StatusCode)
+	}
+
+	var parsed struct {
+		Aliases []string `json:"aliases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("matrix: failed to decode aliases for %s: %w", roomID, err)
+	}
+	return parsed.Aliases, nil
+}
+
+// asRequest issues an authenticated AS request. When actingAs is non-empty
+// it's passed as the `user_id` query parameter, asking the homeserver to
+// perform the action as that puppet rather than the AS itself.
+func (c *Client) asRequest(method, path, actingAs string, body []byte) (*http.Response, error) {
+	endpoint := c.cfg.HomeserverURL + path
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.ASToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	if actingAs != "" {
+		q := req.URL.Query()
+		q.Set("user_id", actingAs)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	return c.httpClient.Do(req)
+}
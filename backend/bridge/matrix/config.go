@@ -0,0 +1,71 @@
+// Package matrix bridges MindBridge circles onto Matrix as an Application
+// Service (AS), so users on Matrix/Element clients can participate in
+// circle chats without the web client. The AS owns a reserved namespace of
+// puppet users (`@mindbridge_*:server`) and room aliases (`#circle_*:server`)
+// on the homeserver, registered out-of-band via a registration YAML; this
+// package reads the resulting tokens from the environment rather than
+// parsing that YAML itself.
+package matrix
+
+import "os"
+
+// Config holds the credentials and namespace prefixes a homeserver issues
+// an Application Service when it's registered via registration.yaml.
+type Config struct {
+	// HomeserverURL is the homeserver's Client-Server / AS API base URL.
+	HomeserverURL string
+	// ASToken authenticates the bridge's requests to the homeserver.
+	ASToken string
+	// HSToken authenticates the homeserver's requests back to the bridge
+	// (the /transactions push endpoint).
+	HSToken string
+	// ServerName is the homeserver's domain, used to build full MXIDs.
+	ServerName string
+	// UserPrefix namespaces puppet users, e.g. "mindbridge_" for
+	// @mindbridge_<userID>:server.
+	UserPrefix string
+	// AliasPrefix namespaces circle room aliases, e.g. "circle_" for
+	// #circle_<circleID>:server.
+	AliasPrefix string
+}
+
+// Enabled reports whether the bridge has enough configuration to register
+// with a homeserver at all.
+func (c Config) Enabled() bool {
+	return c.HomeserverURL != "" && c.ASToken != "" && c.HSToken != ""
+}
+
+// NewConfigFromEnv reads bridge configuration from the environment. The
+// actual as_token/hs_token pair still originates from a registration.yaml
+// handed to the homeserver operator; this just reads the values back out.
+func NewConfigFromEnv() Config {
+	userPrefix := os.Getenv("MATRIX_USER_PREFIX")
+	if userPrefix == "" {
+		userPrefix = "mindbridge_"
+	}
+
+	aliasPrefix := os.Getenv("MATRIX_ALIAS_PREFIX")
+	if aliasPrefix == "" {
+		aliasPrefix = "circle_"
+	}
+
+	return Config{
+		HomeserverURL: os.Getenv("MATRIX_HOMESERVER_URL"),
+		ASToken:       os.Getenv("MATRIX_AS_TOKEN"),
+		HSToken:       os.Getenv("MATRIX_HS_TOKEN"),
+		ServerName:    os.Getenv("MATRIX_SERVER_NAME"),
+		UserPrefix:    userPrefix,
+		AliasPrefix:   aliasPrefix,
+	}
+}
+
+// PuppetMXID returns the full Matrix user ID of the puppet for a
+// MindBridge user.
+func (c Config) PuppetMXID(userID string) string {
+	return "@" + c.UserPrefix + userID + ":" + c.ServerName
+}
+
+// RoomAlias returns the full Matrix room alias for a circle.
+func (c Config) RoomAlias(circleID string) string {
+	return "#" + c.AliasPrefix + circleID + ":" + c.ServerName
+}
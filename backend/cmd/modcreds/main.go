@@ -0,0 +1,65 @@
+// Command modcreds lets a human moderator unlock the encrypted seed
+// credential store (written by setup_moderators.go via storage/encstore)
+// and read a password once, without it ever touching disk in plaintext.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"mindbridge/backend/storage/encstore"
+)
+
+func main() {
+	path := flag.String("path", "MODERATOR_CREDENTIALS.enc", "path to the encrypted credential store")
+	key := flag.String("key", "", "entry key to look up (e.g. a moderator's email); lists all keys if omitted")
+	flag.Parse()
+
+	password := os.Getenv("SEED_ENCRYPTION_PASSWORD")
+	if password == "" {
+		password = promptPassword("Store password: ")
+	}
+
+	store, err := encstore.Open(*path, password)
+	if err != nil {
+		log.Fatalf("Failed to unlock %s: %v", *path, err)
+	}
+
+	if *key == "" {
+		keys := store.List()
+		if len(keys) == 0 {
+			fmt.Println("Store is empty.")
+			return
+		}
+		fmt.Println("Available entries:")
+		for _, k := range keys {
+			fmt.Printf("  %s\n", k)
+		}
+		return
+	}
+
+	entry, err := store.Get(*key)
+	if err != nil {
+		log.Fatalf("Failed to read entry %s: %v", *key, err)
+	}
+
+	fmt.Printf("role:     %s\n", entry.Role)
+	fmt.Printf("email:    %s\n", entry.Email)
+	fmt.Printf("phone:    %s\n", entry.Phone)
+	fmt.Printf("password: %s\n", entry.Password)
+	fmt.Printf("created:  %s\n", entry.CreatedAt.Format("2006-01-02 15:04:05"))
+}
+
+func promptPassword(prompt string) string {
+	fmt.Print(prompt)
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,272 @@
+// Package crypto provides at-rest field encryption for sensitive,
+// free-text data (onboarding disclosures, peer-circle messages) so that a
+// raw Postgres dump never exposes plaintext. It uses envelope encryption:
+// every user gets their own AES-256 data encryption key (DEK), which is
+// itself encrypted ("wrapped") by a single master key-encryption key (KEK)
+// supplied via env/KMS. Only the wrapped DEK is persisted.
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// currentVersion is prefixed to every ciphertext so a future algorithm or
+// key-derivation change can be recognized and migrated without guessing.
+const currentVersion byte = 1
+
+// ErrKeyNotFound is returned by a KeyStore when no wrapped DEK exists yet
+// for a user.
+var ErrKeyNotFound = errors.New("crypto: no wrapped key for user")
+
+// Cipher encrypts and decrypts free-text fields on behalf of a specific
+// user. Implementations are responsible for locating (and, if necessary,
+// provisioning) that user's data encryption key.
+type Cipher interface {
+	EncryptForUser(ctx context.Context, userID, plaintext string) ([]byte, error)
+	DecryptForUser(ctx context.Context, userID string, ciphertext []byte) (string, error)
+}
+
+// KeyStore persists the wrapped (KEK-encrypted) per-user DEK. Callers
+// outside this package implement it against whatever table stores the
+// key material (e.g. a column on User).
+type KeyStore interface {
+	GetWrappedDEK(ctx context.Context, userID string) ([]byte, error)
+	SetWrappedDEK(ctx context.Context, userID string, wrapped []byte) error
+}
+
+// EnvelopeCipher is the default Cipher implementation: AES-256-GCM for
+// both the KEK wrap and the per-user field encryption.
+type EnvelopeCipher struct {
+	kek  []byte
+	keys KeyStore
+}
+
+// NewEnvelopeCipher builds an EnvelopeCipher from a 32-byte master key and
+// a KeyStore used to read/write wrapped per-user DEKs.
+func NewEnvelopeCipher(kek []byte, keys KeyStore) (*EnvelopeCipher, error) {
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("crypto: KEK must be 32 bytes, got %d", len(kek))
+	}
+	return &EnvelopeCipher{kek: kek, keys: keys}, nil
+}
+
+// EncryptForUser encrypts plaintext under the user's DEK, provisioning a
+// fresh DEK on first use. The returned bytes are [version][nonce][ciphertext];
+// callers typically base64-encode this (see Encode) before writing it to
+// a string column.
+func (c *EnvelopeCipher) EncryptForUser(ctx context.Context, userID, plaintext string) ([]byte, error) {
+	dek, err := c.getOrCreateDEK(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: failed to load key for user %s: %w", userID, err)
+	}
+
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("crypto: failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+
+	out := make([]byte, 0, 1+len(nonce)+len(sealed))
+	out = append(out, currentVersion)
+	out = append(out, nonce...)
+	out = append(out, sealed...)
+	return out, nil
+}
+
+// DecryptForUser reverses EncryptForUser using the same user's DEK.
+func (c *EnvelopeCipher) DecryptForUser(ctx context.Context, userID string, ciphertext []byte) (string, error) {
+	if len(ciphertext) < 1 {
+		return "", errors.New("crypto: ciphertext too short")
+	}
+
+	version := ciphertext[0]
+	if version != currentVersion {
+		return "", fmt.Errorf("crypto: unsupported ciphertext version %d", version)
+	}
+
+	wrapped, err := c.keys.GetWrappedDEK(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("crypto: failed to load key for user %s: %w", userID, err)
+	}
+
+	dek, err := c.unwrapDEK(wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := gcm.NonceSize()
+	body := ciphertext[1:]
+	if len(body) < nonceSize {
+		return "", errors.New("crypto: ciphertext too short")
+	}
+
+	nonce, sealed := body[:nonceSize], body[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("crypto: failed to decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// getOrCreateDEK returns the user's unwrapped DEK, generating and
+// persisting a wrapped one if this is the first time the user's data is
+// being encrypted.
+func (c *EnvelopeCipher) getOrCreateDEK(ctx context.Context, userID string) ([]byte, error) {
+	wrapped, err := c.keys.GetWrappedDEK(ctx, userID)
+	if err == nil {
+		return c.unwrapDEK(wrapped)
+	}
+	if !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, fmt.Errorf("crypto: failed to generate DEK: %w", err)
+	}
+
+	wrapped, err = c.wrapDEK(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.keys.SetWrappedDEK(ctx, userID, wrapped); err != nil {
+		return nil, fmt.Errorf("crypto: failed to persist wrapped key: %w", err)
+	}
+
+	return dek, nil
+}
+
+// wrapDEK encrypts a DEK under the master KEK for storage.
+func (c *EnvelopeCipher) wrapDEK(dek []byte) ([]byte, error) {
+	gcm, err := newGCM(c.kek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("crypto: failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nil, nonce, dek, nil)
+
+	out := make([]byte, 0, 1+len(nonce)+len(sealed))
+	out = append(out, currentVersion)
+	out = append(out, nonce...)
+	out = append(out, sealed...)
+	return out, nil
+}
+
+// unwrapDEK decrypts a wrapped DEK using the master KEK.
+func (c *EnvelopeCipher) unwrapDEK(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 1 || wrapped[0] != currentVersion {
+		return nil, errors.New("crypto: unsupported wrapped key version")
+	}
+
+	gcm, err := newGCM(c.kek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	body := wrapped[1:]
+	if len(body) < nonceSize {
+		return nil, errors.New("crypto: wrapped key too short")
+	}
+
+	nonce, sealed := body[:nonceSize], body[nonceSize:]
+	dek, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: failed to unwrap key: %w", err)
+	}
+
+	return dek, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: failed to init AES cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Provision ensures a user has a wrapped DEK without encrypting anything.
+// AuthHandler.Signup calls this right after creating the user so the key
+// exists up front instead of being created lazily by whichever request
+// happens to write encrypted data first.
+func (c *EnvelopeCipher) Provision(ctx context.Context, userID string) error {
+	_, err := c.getOrCreateDEK(ctx, userID)
+	return err
+}
+
+// NewFromEnv builds an EnvelopeCipher from the base64-encoded master KEK
+// in the MASTER_KEK environment variable (backed, in production, by a
+// real KMS that only ever hands back the unwrapped key in memory).
+func NewFromEnv(client *db.PrismaClient) (*EnvelopeCipher, error) {
+	encoded := os.Getenv("MASTER_KEK")
+	if encoded == "" {
+		return nil, errors.New("crypto: MASTER_KEK not set")
+	}
+
+	kek, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: failed to decode MASTER_KEK: %w", err)
+	}
+
+	return NewEnvelopeCipher(kek, NewPrismaKeyStore(client))
+}
+
+// RewrapKey decrypts a wrapped DEK under oldKEK and re-encrypts it under
+// newKEK, without touching the DEK itself or anything encrypted under it.
+// This is the operation a KEK-rotation migration performs for every user.
+func RewrapKey(oldKEK, newKEK, wrapped []byte) ([]byte, error) {
+	old, err := NewEnvelopeCipher(oldKEK, nil)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := old.unwrapDEK(wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := NewEnvelopeCipher(newKEK, nil)
+	if err != nil {
+		return nil, err
+	}
+	return next.wrapDEK(dek)
+}
+
+// Encode base64-encodes a ciphertext produced by EncryptForUser/wrapDEK
+// for storage in a string column.
+func Encode(ciphertext []byte) string {
+	return base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+// Decode reverses Encode.
+func Decode(encoded string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(encoded)
+}
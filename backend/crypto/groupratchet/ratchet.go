@@ -0,0 +1,152 @@
+// Package groupratchet implements the sender-keys primitives circle
+// clients use to encrypt messages end to end: the server only ever sees
+// ciphertext, (sender, counter) metadata, and nacl/box-sealed chain-key
+// distributions it cannot open itself. These functions mirror exactly
+// what a client must do to interoperate - there is no server-side caller
+// for Encrypt/Decrypt/SealChainKey, since the server holds no private
+// keys, but keeping the reference implementation here (rather than only
+// in the web client) is what the socket event and Prisma model shapes in
+// this package are validated against.
+package groupratchet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// KeySize is the length in bytes of an X25519 key, a chain key, and a
+// derived message key.
+const KeySize = 32
+
+var (
+	// ErrSealedTooShort is returned when a sealed chain key is shorter
+	// than a nacl/box overhead + nonce, so it can't possibly be valid.
+	ErrSealedTooShort = errors.New("groupratchet: sealed chain key too short")
+)
+
+// GenerateIdentityKeyPair creates the long-lived X25519 keypair a member
+// publishes once (as UserKey.IdentityPublicKey) and uses to receive
+// SenderKeyDistribution messages for every circle they're in.
+func GenerateIdentityKeyPair() (publicKey, privateKey [KeySize]byte, err error) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return [KeySize]byte{}, [KeySize]byte{}, fmt.Errorf("groupratchet: failed to generate identity key: %w", err)
+	}
+	return *pub, *priv, nil
+}
+
+// GenerateChainKey creates a fresh random chain key for a new key epoch -
+// generated once per circle member per rekey, then ratcheted forward on
+// every message they send.
+func GenerateChainKey() ([KeySize]byte, error) {
+	var chainKey [KeySize]byte
+	if _, err := io.ReadFull(rand.Reader, chainKey[:]); err != nil {
+		return chainKey, fmt.Errorf("groupratchet: failed to generate chain key: %w", err)
+	}
+	return chainKey, nil
+}
+
+// DeriveMessageKey derives the one-time key used to encrypt the message
+// at counter from the sender's current chain key. The chain key itself is
+// never reused for encryption directly.
+func DeriveMessageKey(chainKey [KeySize]byte, counter uint32) ([KeySize]byte, error) {
+	info := make([]byte, len("msg")+4)
+	copy(info, "msg")
+	binary.BigEndian.PutUint32(info[len("msg"):], counter)
+	return hkdfExpand(chainKey, info)
+}
+
+// RatchetForward derives the next chain key from the current one. Callers
+// discard the old chain key immediately after - it's what makes forward
+// secrecy and post-removal lockout work, since a removed member's last
+// known chain key can't derive any key an active member's messages use.
+func RatchetForward(chainKey [KeySize]byte) ([KeySize]byte, error) {
+	return hkdfExpand(chainKey, []byte("ratchet"))
+}
+
+func hkdfExpand(chainKey [KeySize]byte, info []byte) ([KeySize]byte, error) {
+	var out [KeySize]byte
+	reader := hkdf.New(sha256.New, chainKey[:], nil, info)
+	if _, err := io.ReadFull(reader, out[:]); err != nil {
+		return out, fmt.Errorf("groupratchet: HKDF expand failed: %w", err)
+	}
+	return out, nil
+}
+
+// SealChainKey encrypts chainKey to recipientPublicKey so only that
+// member's identity private key can open it - this is what gets stored
+// as a SenderKeyDistribution row.
+func SealChainKey(chainKey [KeySize]byte, recipientPublicKey, senderPrivateKey [KeySize]byte) ([]byte, error) {
+	nonce, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+	return box.Seal(nonce[:], chainKey[:], &nonce, &recipientPublicKey, &senderPrivateKey), nil
+}
+
+// OpenChainKey reverses SealChainKey, recovering the chain key a
+// distribution row was addressed to the caller's identity key.
+func OpenChainKey(sealed []byte, senderPublicKey, recipientPrivateKey [KeySize]byte) ([KeySize]byte, error) {
+	var zero [KeySize]byte
+	if len(sealed) < 24 {
+		return zero, ErrSealedTooShort
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+
+	opened, ok := box.Open(nil, sealed[24:], &nonce, &senderPublicKey, &recipientPrivateKey)
+	if !ok || len(opened) != KeySize {
+		return zero, errors.New("groupratchet: failed to open sealed chain key")
+	}
+
+	var chainKey [KeySize]byte
+	copy(chainKey[:], opened)
+	return chainKey, nil
+}
+
+// Encrypt encrypts plaintext under messageKey with XChaCha20-Poly1305.
+func Encrypt(messageKey [KeySize]byte, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(messageKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("groupratchet: failed to init AEAD: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("groupratchet: failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt reverses Encrypt.
+func Decrypt(messageKey [KeySize]byte, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(messageKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("groupratchet: failed to init AEAD: %w", err)
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("groupratchet: ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+func randomNonce() ([24]byte, error) {
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nonce, fmt.Errorf("groupratchet: failed to generate nonce: %w", err)
+	}
+	return nonce, nil
+}
@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// PrismaKeyStore stores each user's wrapped DEK on their User row.
+type PrismaKeyStore struct {
+	client *db.PrismaClient
+}
+
+// NewPrismaKeyStore returns a KeyStore backed by the User.DataEncryptionKey column.
+func NewPrismaKeyStore(client *db.PrismaClient) *PrismaKeyStore {
+	return &PrismaKeyStore{client: client}
+}
+
+// GetWrappedDEK returns ErrKeyNotFound if the user has no key yet, which
+// tells EnvelopeCipher to provision one on first encrypt.
+func (s *PrismaKeyStore) GetWrappedDEK(ctx context.Context, userID string) ([]byte, error) {
+	user, err := s.client.User.FindUnique(
+		db.User.ID.Equals(userID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := user.DataEncryptionKey()
+	if !ok || encoded == "" {
+		return nil, ErrKeyNotFound
+	}
+
+	return Decode(encoded)
+}
+
+// SetWrappedDEK persists a newly-provisioned wrapped DEK. It refuses to
+// overwrite an existing key so a concurrent first-encrypt race can't
+// silently orphan already-encrypted data.
+func (s *PrismaKeyStore) SetWrappedDEK(ctx context.Context, userID string, wrapped []byte) error {
+	existing, err := s.GetWrappedDEK(ctx, userID)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	encoded := Encode(wrapped)
+	_, err = s.client.User.FindUnique(
+		db.User.ID.Equals(userID),
+	).Update(
+		db.User.DataEncryptionKey.Set(encoded),
+	).Exec(ctx)
+	return err
+}
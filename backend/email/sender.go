@@ -0,0 +1,93 @@
+// Package email provides the low-level transport for outbound mail.
+// services.EmailService composes messages (subject/body) and calls down
+// into a Sender here to actually deliver them, so the transport can be
+// swapped (SMTP in dev, SendGrid in prod) without touching any callers.
+package email
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// Sender delivers a single plain-text email.
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// NewFromEnv selects a Sender implementation based on EMAIL_PROVIDER
+// ("smtp" or "sendgrid", defaulting to "smtp").
+func NewFromEnv() Sender {
+	switch os.Getenv("EMAIL_PROVIDER") {
+	case "sendgrid":
+		return NewSendGridSender(os.Getenv("SENDGRID_API_KEY"), os.Getenv("SMTP_FROM"))
+	default:
+		return NewSMTPSender(
+			os.Getenv("SMTP_FROM"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+		)
+	}
+}
+
+// SMTPSender sends mail over plain SMTP with PLAIN auth.
+type SMTPSender struct {
+	from     string
+	password string
+	host     string
+	port     string
+}
+
+// NewSMTPSender builds an SMTPSender from explicit connection details.
+func NewSMTPSender(from, password, host, port string) *SMTPSender {
+	return &SMTPSender{from: from, password: password, host: host, port: port}
+}
+
+func (s *SMTPSender) Send(to, subject, body string) error {
+	if s.from == "" || s.host == "" {
+		return fmt.Errorf("email: SMTP not configured")
+	}
+
+	auth := smtp.PlainAuth("", s.from, s.password, s.host)
+	message := []byte(fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s", to, subject, body))
+	addr := fmt.Sprintf("%s:%s", s.host, s.port)
+
+	return smtp.SendMail(addr, auth, s.from, []string{to}, message)
+}
+
+// SendGridSender sends mail through the SendGrid HTTP API.
+type SendGridSender struct {
+	apiKey string
+	from   string
+}
+
+// NewSendGridSender builds a SendGridSender from an API key and verified
+// sender address.
+func NewSendGridSender(apiKey, from string) *SendGridSender {
+	return &SendGridSender{apiKey: apiKey, from: from}
+}
+
+func (s *SendGridSender) Send(to, subject, body string) error {
+	if s.apiKey == "" {
+		return fmt.Errorf("email: SendGrid not configured")
+	}
+
+	from := mail.NewEmail("MindBridge", s.from)
+	recipient := mail.NewEmail("", to)
+	message := mail.NewSingleEmail(from, subject, recipient, body, "")
+
+	client := sendgrid.NewSendClient(s.apiKey)
+	resp, err := client.Send(message)
+	if err != nil {
+		return fmt.Errorf("email: SendGrid request failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("email: SendGrid returned status %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	return nil
+}
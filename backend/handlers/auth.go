@@ -2,22 +2,39 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"regexp"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"mindbridge/backend/crypto"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
 	"mindbridge/backend/utils"
 )
 
 type AuthHandler struct {
-	client *db.PrismaClient
+	client       *db.PrismaClient
+	cipher       *crypto.EnvelopeCipher
+	emailService *services.EmailService
 }
 
-func NewAuthHandler(client *db.PrismaClient) *AuthHandler {
-	return &AuthHandler{client: client}
+func NewAuthHandler(client *db.PrismaClient, cipher *crypto.EnvelopeCipher) *AuthHandler {
+	return &AuthHandler{
+		client:       client,
+		cipher:       cipher,
+		emailService: services.NewEmailService(),
+	}
 }
 
+const (
+	resetTokenTTL             = 30 * time.Minute
+	resetTokenRateLimit       = 3
+	resetTokenRateLimitWindow = time.Hour
+)
+
 type SignupRequest struct {
 	FullName       string `json:"fullName" binding:"required"`
 	Email          string `json:"email" binding:"required,email"`
@@ -104,6 +121,13 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
+	// Provision the user's data encryption key up front so every later
+	// write of encrypted fields (onboarding disclosures, circle messages)
+	// has a key ready rather than racing to create one lazily.
+	if err := h.cipher.Provision(ctx, user.ID); err != nil {
+		log.Printf("Failed to provision encryption key for user %s: %v", user.ID, err)
+	}
+
 	// Generate JWT token
 	token, err := utils.GenerateJWT(user.ID, user.Email, string(user.Role), user.OnboardingCompleted)
 	if err != nil {
@@ -269,3 +293,348 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		"createdAt":           user.CreatedAt,
 	})
 }
+
+type NotificationPreferencesRequest struct {
+	Email *bool `json:"email"`
+	Sms   *bool `json:"sms"`
+	InApp *bool `json:"inApp"`
+}
+
+// UpdateNotificationPreferences lets a user opt in or out of individual
+// delivery channels (email, SMS, in-app) for scheduled wellness check-ins,
+// without affecting the others. Omitted fields are left unchanged.
+func (h *AuthHandler) UpdateNotificationPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	params := []db.UserSetParam{}
+	if req.Email != nil {
+		params = append(params, db.User.NotifyEmail.Set(*req.Email))
+	}
+	if req.Sms != nil {
+		params = append(params, db.User.NotifySms.Set(*req.Sms))
+	}
+	if req.InApp != nil {
+		params = append(params, db.User.NotifyInApp.Set(*req.InApp))
+	}
+
+	if len(params) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No preferences provided"})
+		return
+	}
+
+	ctx := context.Background()
+	user, err := h.client.User.FindUnique(
+		db.User.ID.Equals(userID.(string)),
+	).Update(params...).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email": user.NotifyEmail,
+		"sms":   user.NotifySms,
+		"inApp": user.NotifyInApp,
+	})
+}
+
+type RequestPasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RequestPasswordReset issues a single-use reset token and emails it to
+// the user. It always responds with the same message regardless of
+// whether the email is registered, so the endpoint can't be used to
+// enumerate accounts.
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req RequestPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	ctx := context.Background()
+	const genericMessage = "If an account exists for that email, a reset link has been sent"
+
+	user, _ := h.client.User.FindUnique(
+		db.User.Email.Equals(req.Email),
+	).Exec(ctx)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{"message": genericMessage})
+		return
+	}
+
+	allowed, err := h.checkTokenRateLimit(ctx, req.Email, tokenKindPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many reset requests. Please try again later."})
+		return
+	}
+
+	token, tokenHash, err := h.generateUniqueToken(ctx, tokenKindPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	expiresAt := db.DateTime(time.Now().UTC().Add(resetTokenTTL))
+	_, err = h.client.PasswordResetToken.CreateOne(
+		db.PasswordResetToken.Email.Set(req.Email),
+		db.PasswordResetToken.TokenHash.Set(tokenHash),
+		db.PasswordResetToken.ExpiresAt.Set(expiresAt),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	go h.emailService.SendPasswordResetEmail(user.FullName, user.Email, token)
+
+	c.JSON(http.StatusOK, gin.H{"message": genericMessage})
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// ConfirmPasswordReset validates a reset token and updates the user's
+// password, marking the token used so it can't be replayed.
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req ConfirmPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	ctx := context.Background()
+
+	record, err := h.client.PasswordResetToken.FindUnique(
+		db.PasswordResetToken.TokenHash.Equals(utils.HashToken(req.Token)),
+	).Exec(ctx)
+	if err != nil || record.Used || time.Now().UTC().After(time.Time(record.ExpiresAt)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	isStrong, message := utils.ValidatePasswordStrength(req.NewPassword)
+	if !isStrong {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		return
+	}
+
+	if _, err := h.client.User.FindUnique(
+		db.User.Email.Equals(record.Email),
+	).Update(
+		db.User.Password.Set(hashedPassword),
+	).Exec(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if _, err := h.client.PasswordResetToken.FindUnique(
+		db.PasswordResetToken.TokenHash.Equals(record.TokenHash),
+	).Update(
+		db.PasswordResetToken.Used.Set(true),
+	).Exec(ctx); err != nil {
+		log.Printf("Failed to mark reset token used for %s: %v", record.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// RequestEmailVerification issues a single-use verification token and
+// emails it to the authenticated user.
+func (h *AuthHandler) RequestEmailVerification(c *gin.Context) {
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	ctx := context.Background()
+
+	user, err := h.client.User.FindUnique(
+		db.User.ID.Equals(userIDValue.(string)),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	allowed, err := h.checkTokenRateLimit(ctx, user.Email, tokenKindEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many verification requests. Please try again later."})
+		return
+	}
+
+	token, tokenHash, err := h.generateUniqueToken(ctx, tokenKindEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	expiresAt := db.DateTime(time.Now().UTC().Add(resetTokenTTL))
+	_, err = h.client.EmailVerificationToken.CreateOne(
+		db.EmailVerificationToken.Email.Set(user.Email),
+		db.EmailVerificationToken.TokenHash.Set(tokenHash),
+		db.EmailVerificationToken.ExpiresAt.Set(expiresAt),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+		return
+	}
+
+	go h.emailService.SendEmailVerificationEmail(user.FullName, user.Email, token)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+type ConfirmEmailVerificationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ConfirmEmailVerification validates a verification token and marks the
+// owning user's email as verified.
+func (h *AuthHandler) ConfirmEmailVerification(c *gin.Context) {
+	var req ConfirmEmailVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	ctx := context.Background()
+
+	record, err := h.client.EmailVerificationToken.FindUnique(
+		db.EmailVerificationToken.TokenHash.Equals(utils.HashToken(req.Token)),
+	).Exec(ctx)
+	if err != nil || record.Used || time.Now().UTC().After(time.Time(record.ExpiresAt)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	if _, err := h.client.User.FindUnique(
+		db.User.Email.Equals(record.Email),
+	).Update(
+		db.User.EmailVerified.Set(true),
+	).Exec(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	if _, err := h.client.EmailVerificationToken.FindUnique(
+		db.EmailVerificationToken.TokenHash.Equals(record.TokenHash),
+	).Update(
+		db.EmailVerificationToken.Used.Set(true),
+	).Exec(ctx); err != nil {
+		log.Printf("Failed to mark verification token used for %s: %v", record.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+type tokenKind int
+
+const (
+	tokenKindPasswordReset tokenKind = iota
+	tokenKindEmailVerification
+)
+
+// generateUniqueToken generates a random token and retries (uniuri-style)
+// if its hash happens to already exist for the given token kind, which in
+// practice only ever happens on a SHA-256/rand collision.
+func (h *AuthHandler) generateUniqueToken(ctx context.Context, kind tokenKind) (token, tokenHash string, err error) {
+	const maxAttempts = 5
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		token, err = utils.GenerateURLSafeToken()
+		if err != nil {
+			return "", "", err
+		}
+		tokenHash = utils.HashToken(token)
+
+		taken, err := h.tokenHashExists(ctx, kind, tokenHash)
+		if err != nil {
+			return "", "", err
+		}
+		if !taken {
+			return token, tokenHash, nil
+		}
+	}
+
+	return "", "", errors.New("failed to generate a unique token")
+}
+
+func (h *AuthHandler) tokenHashExists(ctx context.Context, kind tokenKind, tokenHash string) (bool, error) {
+	switch kind {
+	case tokenKindPasswordReset:
+		existing, err := h.client.PasswordResetToken.FindUnique(
+			db.PasswordResetToken.TokenHash.Equals(tokenHash),
+		).Exec(ctx)
+		return existing != nil, ignoreNotFound(err)
+	default:
+		existing, err := h.client.EmailVerificationToken.FindUnique(
+			db.EmailVerificationToken.TokenHash.Equals(tokenHash),
+		).Exec(ctx)
+		return existing != nil, ignoreNotFound(err)
+	}
+}
+
+// checkTokenRateLimit enforces the 3-per-hour-per-email cap on issuing
+// reset/verification tokens.
+func (h *AuthHandler) checkTokenRateLimit(ctx context.Context, email string, kind tokenKind) (bool, error) {
+	windowStart := db.DateTime(time.Now().UTC().Add(-resetTokenRateLimitWindow))
+
+	var count int
+	switch kind {
+	case tokenKindPasswordReset:
+		recent, err := h.client.PasswordResetToken.FindMany(
+			db.PasswordResetToken.Email.Equals(email),
+			db.PasswordResetToken.CreatedAt.Gt(windowStart),
+		).Exec(ctx)
+		if err != nil {
+			return false, err
+		}
+		count = len(recent)
+	default:
+		recent, err := h.client.EmailVerificationToken.FindMany(
+			db.EmailVerificationToken.Email.Equals(email),
+			db.EmailVerificationToken.CreatedAt.Gt(windowStart),
+		).Exec(ctx)
+		if err != nil {
+			return false, err
+		}
+		count = len(recent)
+	}
+
+	return count < resetTokenRateLimit, nil
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, db.ErrNotFound) {
+		return nil
+	}
+	return err
+}
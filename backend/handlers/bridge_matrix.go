@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"mindbridge/backend/bridge/matrix"
+	"mindbridge/backend/prisma/db"
+)
+
+// linkCodeTTL is how long a one-time Matrix link code stays claimable.
+const linkCodeTTL = 10 * time.Minute
+
+type BridgeMatrixHandler struct {
+	client *db.PrismaClient
+	bridge *matrix.Bridge
+	cfg    matrix.Config
+}
+
+func NewBridgeMatrixHandler(client *db.PrismaClient, bridge *matrix.Bridge, cfg matrix.Config) *BridgeMatrixHandler {
+	return &BridgeMatrixHandler{client: client, bridge: bridge, cfg: cfg}
+}
+
+// CreateLinkCode mints a one-time code the signed-in user can DM to the
+// bridge bot on Matrix (as "!link <code>") to claim their Matrix MXID.
+func (h *BridgeMatrixHandler) CreateLinkCode(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	code, err := generateLinkCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate link code"})
+		return
+	}
+
+	ctx := context.Background()
+	_, err = h.client.MatrixLinkCode.CreateOne(
+		db.MatrixLinkCode.Code.Set(code),
+		db.MatrixLinkCode.ExpiresAt.Set(time.Now().UTC().Add(linkCodeTTL)),
+		db.MatrixLinkCode.User.Link(db.User.ID.Equals(userID.(string))),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link code"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":      code,
+		"command":   "!link " + code,
+		"expiresAt": time.Now().UTC().Add(linkCodeTTL),
+	})
+}
+
+// Transactions receives pushed Matrix room events from the homeserver.
+// It's authenticated with the AS registration's hs_token rather than a
+// MindBridge user session, matching the Application Service API.
+func (h *BridgeMatrixHandler) Transactions(c *gin.Context) {
+	token := c.Query("access_token")
+	if token == "" {
+		token = c.GetHeader("Authorization")
+	}
+	if token != h.cfg.HSToken && token != "Bearer "+h.cfg.HSToken {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid homeserver token"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read transaction body"})
+		return
+	}
+
+	var payload struct {
+		Events []matrix.TransactionEvent `json:"events"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction payload"})
+		return
+	}
+
+	h.bridge.HandleTransaction(context.Background(), payload.Events)
+
+	// The AS spec requires a 200 with an empty object even when individual
+	// events failed to process, or the homeserver will retry the whole
+	// transaction forever.
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func generateLinkCode() (string, error) {
+	raw := make([]byte, 6)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
+	"mindbridge/backend/webhooks"
+)
+
+type CircleHandler struct {
+	client          *db.PrismaClient
+	matchingService *services.CircleMatchingService
+}
+
+func NewCircleHandler(client *db.PrismaClient, dispatcher *webhooks.Dispatcher) *CircleHandler {
+	return &CircleHandler{
+		client:          client,
+		matchingService: services.NewCircleMatchingService(client, dispatcher),
+	}
+}
+
+type InviteResponse struct {
+	Code string `json:"code"`
+}
+
+// CreateInvite lets an existing circle member generate a code a trusted
+// friend can redeem to join the same circle directly.
+func (h *CircleHandler) CreateInvite(c *gin.Context) {
+	circleID := c.Param("id")
+
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDValue.(string)
+
+	ctx := context.Background()
+
+	code, err := h.matchingService.GenerateInviteCode(ctx, circleID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, InviteResponse{Code: code})
+}
+
+type CircleSettingsRequest struct {
+	ModeratorViewOptIn *bool `json:"moderatorViewOptIn"`
+}
+
+// UpdateSettings lets a circle's moderator configure behavior that only
+// matters once the circle is end-to-end encrypted: since the server never
+// holds chain keys, Content is opaque ciphertext to it regardless, so
+// ModeratorViewOptIn doesn't grant plaintext access - it only decides
+// whether the moderator dashboard is allowed to load the circle's message
+// metadata (sender, timestamp, read receipts) at all. Circles default to
+// opted out, meaning GetCircleMessages refuses moderator access entirely
+// once Encrypted is set, to avoid the dashboard quietly degrading into a
+// metadata-only view nobody asked for. There is no search over encrypted
+// circles in any configuration - search only ever ran over server-readable
+// plaintext, which an encrypted circle never produces.
+func (h *CircleHandler) UpdateSettings(c *gin.Context) {
+	circleID := c.Param("id")
+
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDValue.(string)
+
+	var req CircleSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	ctx := context.Background()
+
+	circle, err := h.client.Circle.FindUnique(
+		db.Circle.ID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+	if circle.ModeratorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the circle's moderator can change its settings"})
+		return
+	}
+
+	params := []db.CircleSetParam{}
+	if req.ModeratorViewOptIn != nil {
+		params = append(params, db.Circle.ModeratorViewOptIn.Set(*req.ModeratorViewOptIn))
+	}
+
+	updated, err := h.client.Circle.FindUnique(
+		db.Circle.ID.Equals(circleID),
+	).Update(params...).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update circle settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"encrypted":          updated.Encrypted,
+		"moderatorViewOptIn": updated.ModeratorViewOptIn,
+	})
+}
+
+type JoinCircleRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// JoinCircle redeems a trusted-peer invite code, adding the authenticated
+// user directly to the inviter's circle.
+func (h *CircleHandler) JoinCircle(c *gin.Context) {
+	var req JoinCircleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDValue.(string)
+
+	ctx := context.Background()
+
+	circle, err := h.matchingService.RedeemInviteCode(ctx, req.Code, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"circleId":   circle.ID,
+		"circleName": circle.Name,
+	})
+}
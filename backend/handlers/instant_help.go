@@ -2,26 +2,57 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"mindbridge/backend/prisma/db"
 	"mindbridge/backend/services"
+	"mindbridge/backend/webhooks"
 )
 
+// streamKeepaliveInterval is how often ChatStream writes an SSE comment
+// line while waiting on the next chunk, so proxies and browsers that time
+// out idle connections don't drop a slow-starting generation.
+const streamKeepaliveInterval = 15 * time.Second
+
 type InstantHelpHandler struct {
-	geminiService *services.GeminiService
-	client        *db.PrismaClient
+	geminiService  *services.GeminiService
+	safetyPipeline *services.SafetyPipeline
+	emailService   *services.EmailService
+	client         *db.PrismaClient
+	dispatcher     *webhooks.Dispatcher
 }
 
-func NewInstantHelpHandler(client *db.PrismaClient, apiKey string) *InstantHelpHandler {
+func NewInstantHelpHandler(client *db.PrismaClient, apiKey string, dispatcher *webhooks.Dispatcher) *InstantHelpHandler {
+	geminiService := services.NewGeminiService(apiKey)
 	return &InstantHelpHandler{
-		geminiService: services.NewGeminiService(apiKey),
-		client:        client,
+		geminiService:  geminiService,
+		safetyPipeline: services.NewSafetyPipeline(geminiService),
+		emailService:   services.NewEmailService(),
+		client:         client,
+		dispatcher:     dispatcher,
 	}
 }
 
+// crisisResourcesPrefix is prepended to a Chat reply once the safety
+// pipeline puts either the user's message or the model's own reply at
+// high or critical severity, so the resources show up even if Anchor's
+// reply itself didn't think to include them.
+const crisisResourcesPrefix = "Before anything else - if you're in immediate danger or crisis, please reach out right now:\n" +
+	"• National Suicide Prevention Lifeline: 988\n" +
+	"• Crisis Text Line: Text HOME to 741741\n" +
+	"• Campus Counseling Center (available 24/7)\n\n"
+
+// softLockMessage is returned in place of a Gemini reply once
+// SafetyPipeline's circuit breaker trips for a user, handing the
+// conversation off to a human instead of continuing to auto-respond.
+const softLockMessage = "We've paused Anchor for your account after a few serious safety flags in a short period, so a real person can follow up with you directly. Please reach out to the Campus Counseling Center (available 24/7) or call 988 if you need support right now."
+
 type ChatMessage struct {
 	Role string `json:"role"` // "user" or "model"
 	Text string `json:"text"`
@@ -36,6 +67,15 @@ type ChatResponse struct {
 	Response string `json:"response"`
 }
 
+// cannedCrisisMessage replaces a streaming reply the moment it drifts into
+// crisis territory, so a user never sees the model trail off into
+// self-harm content while tokens are still arriving.
+const cannedCrisisMessage = "I want to pause here - it sounds like you might be going through something really serious right now. Please reach out for immediate support:\n\n" +
+	"• National Suicide Prevention Lifeline: 988\n" +
+	"• Crisis Text Line: Text HOME to 741741\n" +
+	"• Campus Counseling Center (available 24/7)\n\n" +
+	"You deserve support from someone who can help right now."
+
 // Chat handles instant help chat requests
 func (h *InstantHelpHandler) Chat(c *gin.Context) {
 	ctx := context.Background()
@@ -59,6 +99,11 @@ func (h *InstantHelpHandler) Chat(c *gin.Context) {
 		return
 	}
 
+	if h.safetyPipeline.IsSoftLocked(userIDStr) {
+		c.JSON(http.StatusOK, ChatResponse{Response: softLockMessage})
+		return
+	}
+
 	log.Printf("Instant help request from user %s: %s", userIDStr, req.Message)
 
 	// Convert history to Gemini format
@@ -70,6 +115,8 @@ func (h *InstantHelpHandler) Chat(c *gin.Context) {
 		}
 	}
 
+	inboundCheck := h.safetyPipeline.Check(ctx, services.SafetyScopeChatMessage, req.Message)
+
 	// Get response from Gemini
 	response, err := h.geminiService.Chat(geminiHistory, req.Message)
 	if err != nil {
@@ -82,6 +129,12 @@ func (h *InstantHelpHandler) Chat(c *gin.Context) {
 
 	log.Printf("Instant help response for user %s: %s", userIDStr, response)
 
+	outboundCheck := h.safetyPipeline.Check(ctx, services.SafetyScopeChatMessage, response)
+	if inboundCheck.Escalate() || outboundCheck.Escalate() {
+		response = crisisResourcesPrefix + response
+		h.recordSafetyEscalation(ctx, userIDStr, inboundCheck, outboundCheck)
+	}
+
 	// Save user message to database
 	_, err = h.client.InstantHelpMessage.CreateOne(
 		db.InstantHelpMessage.User.Link(db.User.ID.Equals(userIDStr)),
@@ -109,6 +162,82 @@ func (h *InstantHelpHandler) Chat(c *gin.Context) {
 	})
 }
 
+// chatSeverityRank mirrors services.SafetyVerdict's severity strings so
+// recordSafetyEscalation can pick the worse of the inbound/outbound checks
+// without reaching into the services package's own ranking.
+var chatSeverityRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// pipelineSeverity reduces a PipelineResult to a single severity and its
+// categories, preferring the Gemini second-pass verdict when present since
+// it's a more confident read than the regex pass's own Matches.
+func pipelineSeverity(result services.PipelineResult) (string, []string) {
+	if result.GeminiVerdict != nil {
+		return result.GeminiVerdict.Severity, result.GeminiVerdict.Categories
+	}
+
+	severity := "low"
+	for _, match := range result.Matches {
+		if chatSeverityRank[match.Severity] > chatSeverityRank[severity] {
+			severity = match.Severity
+		}
+	}
+	categories := make([]string, 0, len(result.CategoryScores))
+	for category := range result.CategoryScores {
+		categories = append(categories, string(category))
+	}
+	return severity, categories
+}
+
+// recordSafetyEscalation writes a SafetyIncident row for the worse of the
+// inbound and outbound safety checks, fires the crisis-detected webhook
+// event, and - for critical severity - bumps the circuit breaker and emails
+// the escalation inbox.
+func (h *InstantHelpHandler) recordSafetyEscalation(ctx context.Context, userIDStr string, inbound, outbound services.PipelineResult) {
+	inboundSeverity, inboundCategories := pipelineSeverity(inbound)
+	outboundSeverity, outboundCategories := pipelineSeverity(outbound)
+
+	severity := inboundSeverity
+	categories := inboundCategories
+	if chatSeverityRank[outboundSeverity] > chatSeverityRank[inboundSeverity] {
+		severity = outboundSeverity
+		categories = outboundCategories
+	}
+
+	if _, err := h.client.SafetyIncident.CreateOne(
+		db.SafetyIncident.User.Link(db.User.ID.Equals(userIDStr)),
+		db.SafetyIncident.Severity.Set(severity),
+		db.SafetyIncident.Categories.Set(categories),
+		db.SafetyIncident.Reason.Set("Instant help safety classifier"),
+	).Exec(ctx); err != nil {
+		log.Printf("Failed to record safety incident for user %s: %v", userIDStr, err)
+	}
+
+	h.dispatcher.Fire(ctx, webhooks.EventCrisisDetected, userIDStr)
+	h.dispatcher.Fire(ctx, webhooks.EventInstantHelpEscalated, userIDStr)
+
+	if severity != "critical" {
+		return
+	}
+
+	h.dispatcher.Fire(ctx, webhooks.EventRiskCritical, userIDStr)
+
+	if h.safetyPipeline.RecordCritical(userIDStr) {
+		log.Printf("Safety circuit breaker tripped for user %s", userIDStr)
+	}
+
+	go func() {
+		alertCtx := context.Background()
+		user, err := h.client.User.FindUnique(db.User.ID.Equals(userIDStr)).Exec(alertCtx)
+		if err != nil {
+			log.Printf("Failed to load user %s for safety alert email: %v", userIDStr, err)
+			return
+		}
+		if err := h.emailService.SendCriticalRiskAlert(user.FullName, user.Email, "Instant help safety classifier", 0, 0, categories); err != nil {
+			log.Printf("Failed to send safety alert email for user %s: %v", userIDStr, err)
+		}
+	}()
+}
+
 // GetHistory retrieves the chat history for the authenticated user
 func (h *InstantHelpHandler) GetHistory(c *gin.Context) {
 	ctx := context.Background()
@@ -149,3 +278,153 @@ func (h *InstantHelpHandler) GetHistory(c *gin.Context) {
 		"history": history,
 	})
 }
+
+// ChatStream streams the Anchor reply token-by-token over text/event-stream
+// instead of waiting for the full response, so a user typing about
+// something urgent sees the first words within a second or two. The
+// upstream Gemini request is bound to the client's request context, so
+// navigating away closes the underlying HTTP body immediately.
+func (h *InstantHelpHandler) ChatStream(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+	userIDStr := userID.(string)
+
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message cannot be empty"})
+		return
+	}
+
+	if h.safetyPipeline.IsSoftLocked(userIDStr) {
+		c.JSON(http.StatusOK, ChatResponse{Response: softLockMessage})
+		return
+	}
+
+	log.Printf("Instant help stream request from user %s: %s", userIDStr, req.Message)
+
+	geminiHistory := make([]services.GeminiMessage, len(req.History))
+	for i, msg := range req.History {
+		geminiHistory[i] = services.GeminiMessage{
+			Role: msg.Role,
+			Text: msg.Text,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	inboundCheck := h.safetyPipeline.Check(ctx, services.SafetyScopeChatMessage, req.Message)
+
+	chunks, errs := h.geminiService.ChatStream(ctx, geminiHistory, req.Message)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	var full strings.Builder
+	interrupted := false
+	disconnected := false
+
+	keepalive := time.NewTicker(streamKeepaliveInterval)
+	defer keepalive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			disconnected = true
+			cancel()
+			return false
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			return true
+		case chunk, ok := <-chunks:
+			if !ok {
+				return false
+			}
+
+			full.WriteString(chunk)
+
+			if services.ContainsCrisisKeyword(full.String()) {
+				interrupted = true
+				cancel()
+				h.dispatcher.Fire(context.Background(), webhooks.EventCrisisDetected, userIDStr)
+				c.SSEvent("crisis", cannedCrisisMessage)
+				return false
+			}
+
+			c.SSEvent("chunk", gin.H{"delta": chunk})
+			return true
+		}
+	})
+
+	streamFailed := false
+	if err, ok := <-errs; ok && err != nil {
+		log.Printf("Gemini stream error for user %s: %v", userIDStr, err)
+		c.SSEvent("error", "Failed to get response from Anchor. Please try again.")
+		streamFailed = true
+	}
+
+	// The request context may already be canceled (client disconnect, or
+	// our own crisis interrupt), so everything from here on uses a fresh
+	// background context rather than inheriting that cancellation.
+	persistCtx := context.Background()
+
+	response := full.String()
+
+	// The mid-stream keyword interrupt already replaced the reply with a
+	// complete canned crisis message and fired the crisis webhook event -
+	// running the outbound pipeline again here and escalating a second
+	// time would just double the alert, same bug chunk3-5 fixed in Chat.
+	if !interrupted && response != "" {
+		outboundCheck := h.safetyPipeline.Check(persistCtx, services.SafetyScopeChatMessage, response)
+		if inboundCheck.Escalate() || outboundCheck.Escalate() {
+			response = crisisResourcesPrefix + response
+			h.recordSafetyEscalation(persistCtx, userIDStr, inboundCheck, outboundCheck)
+		}
+	}
+
+	persisted := response
+	if interrupted {
+		persisted = cannedCrisisMessage
+	}
+	// A crisis interrupt replaces the reply with a complete canned
+	// message, so it isn't partial - only a client disconnect or an
+	// upstream error before the model finished is.
+	partial := (disconnected || streamFailed) && !interrupted
+
+	if persisted == "" {
+		c.SSEvent("done", gin.H{"messageId": ""})
+		return
+	}
+
+	if _, err := h.client.InstantHelpMessage.CreateOne(
+		db.InstantHelpMessage.User.Link(db.User.ID.Equals(userIDStr)),
+		db.InstantHelpMessage.Role.Set("user"),
+		db.InstantHelpMessage.Content.Set(req.Message),
+	).Exec(persistCtx); err != nil {
+		log.Printf("Failed to save user message for user %s: %v", userIDStr, err)
+	}
+
+	modelMessage, err := h.client.InstantHelpMessage.CreateOne(
+		db.InstantHelpMessage.User.Link(db.User.ID.Equals(userIDStr)),
+		db.InstantHelpMessage.Role.Set("model"),
+		db.InstantHelpMessage.Content.Set(persisted),
+		db.InstantHelpMessage.Partial.Set(partial),
+	).Exec(persistCtx)
+	if err != nil {
+		log.Printf("Failed to save model response for user %s: %v", userIDStr, err)
+		c.SSEvent("done", gin.H{"messageId": ""})
+		return
+	}
+
+	c.SSEvent("done", gin.H{"messageId": modelMessage.ID})
+}
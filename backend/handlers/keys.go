@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
+)
+
+type KeysHandler struct {
+	client *db.PrismaClient
+	e2ee   *services.E2EEService
+}
+
+func NewKeysHandler(client *db.PrismaClient, e2ee *services.E2EEService) *KeysHandler {
+	return &KeysHandler{client: client, e2ee: e2ee}
+}
+
+type KeyBundleRequest struct {
+	IdentityPublicKey string `json:"identityPublicKey" binding:"required"`
+	SigningPublicKey  string `json:"signingPublicKey" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+}
+
+type KeyBundleResponse struct {
+	UserID            string `json:"userId"`
+	IdentityPublicKey string `json:"identityPublicKey"`
+	SigningPublicKey  string `json:"signingPublicKey"`
+}
+
+// UploadBundle publishes the authenticated user's long-lived identity and
+// signing key bundle. It's the REST counterpart to the key_bundle_upload
+// socket event - clients use this one to publish (or a peer's bundle to
+// verify) before they've joined a circle's socket room, since sealing a
+// chain key to a peer requires knowing their identity key up front.
+func (h *KeysHandler) UploadBundle(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+	userIDStr := userID.(string)
+
+	var req KeyBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	identityKey, err := base64.StdEncoding.DecodeString(req.IdentityPublicKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "identityPublicKey must be base64"})
+		return
+	}
+	signingKey, err := base64.StdEncoding.DecodeString(req.SigningPublicKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signingPublicKey must be base64"})
+		return
+	}
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signature must be base64"})
+		return
+	}
+
+	if !h.e2ee.VerifyBundle(identityKey, signingKey, signature) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Signature does not attest to the identity key"})
+		return
+	}
+
+	ctx := context.Background()
+
+	if _, err := h.client.UserKey.UpsertOne(
+		db.UserKey.UserID.Equals(userIDStr),
+	).Create(
+		db.UserKey.User.Link(db.User.ID.Equals(userIDStr)),
+		db.UserKey.IdentityPublicKey.Set(req.IdentityPublicKey),
+		db.UserKey.SigningPublicKey.Set(req.SigningPublicKey),
+	).Update(
+		db.UserKey.IdentityPublicKey.Set(req.IdentityPublicKey),
+		db.UserKey.SigningPublicKey.Set(req.SigningPublicKey),
+	).Exec(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store key bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GetBundle returns another user's published identity and signing keys, so
+// a client can bind a circle peer's bundle to its signing key before
+// sealing a chain key to it.
+func (h *KeysHandler) GetBundle(c *gin.Context) {
+	if _, exists := c.Get("userID"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	targetUserID := c.Param("userId")
+
+	key, err := h.client.UserKey.FindUnique(
+		db.UserKey.UserID.Equals(targetUserID),
+	).Exec(context.Background())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No key bundle published for this user"})
+		return
+	}
+
+	signingKey, _ := key.SigningPublicKey()
+
+	c.JSON(http.StatusOK, KeyBundleResponse{
+		UserID:            targetUserID,
+		IdentityPublicKey: key.IdentityPublicKey,
+		SigningPublicKey:  signingKey,
+	})
+}
@@ -1,26 +1,33 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"mindbridge/backend/crypto"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/storage"
 )
 
+// imagePresignExpiry is how long a presigned GET URL for a message image
+// is valid, issued fresh on every GetImage request rather than cached.
+const imagePresignExpiry = 15 * time.Minute
+
 type MessageHandler struct {
 	client *db.PrismaClient
+	cipher *crypto.EnvelopeCipher
+	store  storage.Store
 }
 
-func NewMessageHandler(client *db.PrismaClient) *MessageHandler {
-	return &MessageHandler{client: client}
+func NewMessageHandler(client *db.PrismaClient, cipher *crypto.EnvelopeCipher, store storage.Store) *MessageHandler {
+	return &MessageHandler{client: client, cipher: cipher, store: store}
 }
 
 type MessageWithSender struct {
@@ -33,6 +40,10 @@ type MessageWithSender struct {
 	ImageURL     *string  `json:"imageUrl"`
 	CreatedAt    string   `json:"createdAt"`
 	ReadBy       []string `json:"readBy"`
+	// Encrypted reports whether Content is opaque client-side ciphertext
+	// (the circle's Encrypted flag was set) rather than server-decrypted
+	// plaintext.
+	Encrypted bool `json:"encrypted"`
 }
 
 // GetCircleMessages retrieves messages for a circle
@@ -58,6 +69,15 @@ func (h *MessageHandler) GetCircleMessages(c *gin.Context) {
 
 	isModerator := circle.ModeratorID == userID
 
+	// The server holds no chain keys for an end-to-end encrypted circle,
+	// so a moderator gains nothing by loading its messages unless the
+	// circle has explicitly opted in - otherwise it's just ciphertext and
+	// metadata nobody asked to expose to the dashboard.
+	if isModerator && circle.Encrypted && !circle.ModeratorViewOptIn {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Moderator view is disabled for this end-to-end encrypted circle"})
+		return
+	}
+
 	if !isModerator {
 		membership, err := h.client.CircleMembership.FindFirst(
 			db.CircleMembership.UserID.Equals(userID),
@@ -75,6 +95,7 @@ func (h *MessageHandler) GetCircleMessages(c *gin.Context) {
 	).With(
 		db.Message.Sender.Fetch(),
 		db.Message.ReadReceipts.Fetch(),
+		db.Message.Image.Fetch(),
 	).OrderBy(
 		db.Message.CreatedAt.Order(db.ASC),
 	).Exec(ctx)
@@ -97,8 +118,9 @@ func (h *MessageHandler) GetCircleMessages(c *gin.Context) {
 		}
 
 		var imageURL *string
-		if img, ok := msg.ImageURL(); ok {
-			imageURL = &img
+		if _, ok := msg.Image(); ok {
+			url := fmt.Sprintf("/api/messages/image/%s", msg.ID)
+			imageURL = &url
 		}
 
 		// Collect read by user IDs
@@ -107,32 +129,53 @@ func (h *MessageHandler) GetCircleMessages(c *gin.Context) {
 			readBy[j] = receipt.UserID
 		}
 
+		// An end-to-end encrypted circle's Content is already the client's
+		// ciphertext - the server never wrapped it in at-rest encryption
+		// and has no chain key to decrypt it with anyway.
+		content := msg.Content
+		if !circle.Encrypted {
+			decrypted, err := h.decryptContent(ctx, sender.ID, msg.Content)
+			if err != nil {
+				log.Printf("Failed to decrypt message %s: %v", msg.ID, err)
+				decrypted = ""
+			}
+			content = decrypted
+		}
+
 		responseMessages[i] = MessageWithSender{
 			ID:           msg.ID,
 			CircleID:     msg.CircleID,
 			SenderID:     sender.ID,
 			SenderName:   sender.FullName,
 			SenderAvatar: profilePic,
-			Content:      msg.Content,
+			Content:      content,
 			ImageURL:     imageURL,
 			CreatedAt:    msg.CreatedAt.Format(time.RFC3339),
 			ReadBy:       readBy,
+			Encrypted:    circle.Encrypted,
 		}
 	}
 
 	c.JSON(http.StatusOK, responseMessages)
 }
 
-// UploadImage handles image uploads for messages
+// UploadImage handles image uploads for messages. The image is decoded,
+// re-encoded (which strips EXIF and any other metadata riding along with
+// the pixel data), thumbnailed, and pushed to the configured storage.Store
+// under a content-hash key, so two users uploading the same picture share
+// one object instead of paying for it twice. It returns an imageId, not a
+// URL - the client includes that in the imageId field of the socket
+// message payload, and the server links it to the Message row when the
+// message itself is created.
 func (h *MessageHandler) UploadImage(c *gin.Context) {
-	// Get user ID from context (for authentication check)
-	_, exists := c.Get("userID")
+	ctx := context.Background()
+
+	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Parse multipart form
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
@@ -140,61 +183,141 @@ func (h *MessageHandler) UploadImage(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// Validate file type
-	contentType := header.Header.Get("Content-Type")
-	allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp"}
-	isValidType := false
-	for _, t := range allowedTypes {
-		if contentType == t {
-			isValidType = true
-			break
-		}
+	// Validate file size (max 5MB)
+	if header.Size > 5*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large. Maximum size is 5MB"})
+		return
 	}
 
-	if !isValidType {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only images are allowed"})
+	raw, err := io.ReadAll(io.LimitReader(file, 5*1024*1024))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if header.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large. Maximum size is 5MB"})
+	// decodeAndProcessImage is also what rejects malformed or polyglot
+	// files - image.Decode only succeeds against an actual JPEG/PNG
+	// pixel stream, regardless of what the client claimed Content-Type
+	// or the filename extension was.
+	processed, err := decodeAndProcessImage(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or unsupported image file"})
 		return
 	}
 
-	// Create uploads directory if it doesn't exist
-	uploadsDir := "./uploads/messages"
-	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+	existing, err := h.client.MessageImage.FindFirst(
+		db.MessageImage.Sha256.Equals(processed.OriginalHash),
+	).Exec(ctx)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for existing upload"})
+		return
+	}
+
+	if existing != nil {
+		c.JSON(http.StatusOK, gin.H{"imageId": existing.ID})
 		return
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
-	filename := fmt.Sprintf("%s_%s%s", time.Now().Format("20060102_150405"), uuid.New().String()[:8], ext)
-	filePath := filepath.Join(uploadsDir, filename)
+	objectKey := fmt.Sprintf("originals/%s", processed.OriginalHash)
+	thumbnailKey := fmt.Sprintf("thumbnails/%s", processed.ThumbnailHash)
 
-	// Save file
-	out, err := os.Create(filePath)
+	if err := h.store.Put(ctx, objectKey, bytes.NewReader(processed.Original), int64(len(processed.Original)), processed.Mime); err != nil {
+		log.Printf("Failed to store image %s: %v", objectKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	if err := h.store.Put(ctx, thumbnailKey, bytes.NewReader(processed.Thumbnail), int64(len(processed.Thumbnail)), processed.Mime); err != nil {
+		log.Printf("Failed to store thumbnail %s: %v", thumbnailKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	image, err := h.client.MessageImage.CreateOne(
+		db.MessageImage.Uploader.Link(db.User.ID.Equals(userID.(string))),
+		db.MessageImage.ObjectKey.Set(objectKey),
+		db.MessageImage.ThumbnailKey.Set(thumbnailKey),
+		db.MessageImage.Mime.Set(processed.Mime),
+		db.MessageImage.Size.Set(len(processed.Original)),
+		db.MessageImage.Sha256.Set(processed.OriginalHash),
+		db.MessageImage.Width.Set(processed.Width),
+		db.MessageImage.Height.Set(processed.Height),
+	).Exec(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record upload"})
 		return
 	}
-	defer out.Close()
 
-	if _, err := io.Copy(out, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	c.JSON(http.StatusOK, gin.H{"imageId": image.ID})
+}
+
+// GetImage serves the original image attached to message id, after
+// checking the requester belongs to that message's circle. When the
+// configured Store supports presigned URLs (S3Store), the client is
+// redirected straight to the bucket instead of the bytes being proxied
+// through this server.
+func (h *MessageHandler) GetImage(c *gin.Context) {
+	ctx := context.Background()
+	messageID := c.Param("id")
+
+	userIDInterface, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	userID := userIDInterface.(string)
 
-	// Return URL for the uploaded image
-	// In production, you might want to use a CDN or cloud storage
-	imageURL := fmt.Sprintf("/uploads/messages/%s", filename)
+	message, err := h.client.Message.FindUnique(
+		db.Message.ID.Equals(messageID),
+	).With(
+		db.Message.Image.Fetch(),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"imageUrl": imageURL,
-		"filename": filename,
-	})
+	image, ok := message.Image()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message has no image"})
+		return
+	}
+
+	circle, err := h.client.Circle.FindUnique(
+		db.Circle.ID.Equals(message.CircleID),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circle not found"})
+		return
+	}
+
+	if circle.ModeratorID != userID {
+		membership, err := h.client.CircleMembership.FindFirst(
+			db.CircleMembership.UserID.Equals(userID),
+			db.CircleMembership.CircleID.Equals(message.CircleID),
+		).Exec(ctx)
+		if err != nil || membership == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this image"})
+			return
+		}
+	}
+
+	if url, ok, err := h.store.PresignGet(ctx, image.ObjectKey, imagePresignExpiry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate image URL"})
+		return
+	} else if ok {
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	body, err := h.store.Get(ctx, image.ObjectKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load image"})
+		return
+	}
+	defer body.Close()
+
+	c.DataFromReader(http.StatusOK, int64(image.Size), image.Mime, body, nil)
 }
 
 // GetCircleMembers retrieves members of a circle
@@ -357,3 +480,13 @@ func (h *MessageHandler) GetUserCircle(c *gin.Context) {
 	})
 	return
 }
+
+// decryptContent reverses the at-rest encryption applied to Message.Content,
+// keyed on the sending user's DEK.
+func (h *MessageHandler) decryptContent(ctx context.Context, senderID, encoded string) (string, error) {
+	ciphertext, err := crypto.Decode(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	return h.cipher.DecryptForUser(ctx, senderID, ciphertext)
+}
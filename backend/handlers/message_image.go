@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// thumbnailMaxDim is the longest edge a generated thumbnail is scaled to.
+const thumbnailMaxDim = 256
+
+// processedImage is what decodeAndProcessImage produces from a raw upload:
+// an original re-encoded from its decoded pixels (which drops any EXIF or
+// other metadata appended around the pixel data, since the encoder only
+// ever writes back what the decoder exposed) plus a downscaled thumbnail,
+// both already hashed for dedup.
+type processedImage struct {
+	Original      []byte
+	OriginalHash  string
+	Mime          string
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailHash string
+}
+
+// decodeAndProcessImage decodes raw as a JPEG or PNG, rejecting anything
+// else (including polyglot files that only look like an image to a naive
+// extension/content-type check). Re-encoding from the decoded pixels
+// strips EXIF and any other metadata the original file carried - worth
+// doing unconditionally on a mental-health platform, where an image's
+// embedded GPS coordinates or device info is exactly the kind of thing a
+// user uploading it may not realize is there.
+func decodeAndProcessImage(raw []byte) (*processedImage, error) {
+	img, format, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid image: %w", err)
+	}
+
+	var mime string
+	var encode func(img image.Image) ([]byte, error)
+	switch format {
+	case "jpeg":
+		mime = "image/jpeg"
+		encode = encodeJPEG
+	case "png":
+		mime = "image/png"
+		encode = encodePNG
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+
+	original, err := encode(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	thumbnail := nearestNeighborThumbnail(img, thumbnailMaxDim)
+	thumbnailBytes, err := encode(thumbnail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return &processedImage{
+		Original:      original,
+		OriginalHash:  sha256Hex(original),
+		Mime:          mime,
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		Thumbnail:     thumbnailBytes,
+		ThumbnailHash: sha256Hex(thumbnailBytes),
+	}, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// nearestNeighborThumbnail scales img down so its longer edge is maxDim,
+// preserving aspect ratio. A simple nearest-neighbor sample is good enough
+// for a chat thumbnail and avoids pulling in an image-resampling
+// dependency for this alone.
+func nearestNeighborThumbnail(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	if srcW <= maxDim && srcH <= maxDim {
+		return img
+	}
+
+	dstW, dstH := maxDim, maxDim
+	if srcW > srcH {
+		dstH = srcH * maxDim / srcW
+	} else {
+		dstW = srcW * maxDim / srcH
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		srcY := bounds.Min.Y + y*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			srcX := bounds.Min.X + x*srcW/dstW
+			dst.Set(x, y, color.RGBAModel.Convert(img.At(srcX, srcY)))
+		}
+	}
+
+	return dst
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
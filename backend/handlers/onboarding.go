@@ -9,22 +9,36 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"mindbridge/backend/crypto"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/notifier"
 	"mindbridge/backend/services"
 	"mindbridge/backend/utils"
+	"mindbridge/backend/webhooks"
+	"mindbridge/backend/websocket"
 )
 
 type OnboardingHandler struct {
-	client          *db.PrismaClient
-	matchingService *services.CircleMatchingService
-	emailService    *services.EmailService
+	client              *db.PrismaClient
+	matchingService     *services.CircleMatchingService
+	emailService        *services.EmailService
+	cipher              *crypto.EnvelopeCipher
+	socketServer        *websocket.SocketServer
+	dispatcher          *webhooks.Dispatcher
+	checkInPlanner      *notifier.Planner
+	notificationPlanner *notifier.NotificationPlanner
 }
 
-func NewOnboardingHandler(client *db.PrismaClient) *OnboardingHandler {
+func NewOnboardingHandler(client *db.PrismaClient, cipher *crypto.EnvelopeCipher, socketServer *websocket.SocketServer, dispatcher *webhooks.Dispatcher, notificationPlanner *notifier.NotificationPlanner) *OnboardingHandler {
 	return &OnboardingHandler{
-		client:          client,
-		matchingService: services.NewCircleMatchingService(client),
-		emailService:    services.NewEmailService(),
+		client:              client,
+		matchingService:     services.NewCircleMatchingService(client, dispatcher),
+		emailService:        services.NewEmailService(),
+		cipher:              cipher,
+		socketServer:        socketServer,
+		dispatcher:          dispatcher,
+		checkInPlanner:      notifier.NewPlanner(client),
+		notificationPlanner: notificationPlanner,
 	}
 }
 
@@ -60,6 +74,22 @@ type OnboardingResponsePayload struct {
 	OnboardingDone     bool             `json:"onboardingDone"`
 }
 
+// ExtendedScreeningRequest carries the remaining PHQ-9 items (3-9) and
+// GAD-7 items (3-7) not already covered by the PHQ-2/GAD-2 short form in
+// OnboardingRequest.Wellbeing. PhqItems[6] is PHQ-9 item 9, the
+// self-harm question.
+type ExtendedScreeningRequest struct {
+	PhqItems []int `json:"phqItems"`
+	GadItems []int `json:"gadItems"`
+}
+
+type ExtendedScreeningResponsePayload struct {
+	Phq9Total    int    `json:"phq9Total"`
+	Gad7Total    int    `json:"gad7Total"`
+	Phq9Severity string `json:"phq9Severity"`
+	Gad7Severity string `json:"gad7Severity"`
+}
+
 var (
 	allowedTopics = map[string]struct{}{
 		"Exam stress":           {},
@@ -120,7 +150,12 @@ func (h *OnboardingHandler) GetOnboarding(c *gin.Context) {
 
 	otherTopicValue := ""
 	if value, present := onboardingRecord.OtherTopic(); present {
-		otherTopicValue = string(value)
+		decrypted, decryptErr := h.decryptOtherTopic(ctx, userID, string(value))
+		if decryptErr != nil {
+			log.Printf("Failed to decrypt other-topic for user %s: %v", userID, decryptErr)
+		} else {
+			otherTopicValue = decrypted
+		}
 	}
 
 	payload := OnboardingResponsePayload{
@@ -191,7 +226,12 @@ func (h *OnboardingHandler) SubmitOnboarding(c *gin.Context) {
 
 	var prismaOtherTopic *db.String
 	if hasOther && otherTopic != "" {
-		tmp := db.String(otherTopic)
+		encrypted, encryptErr := h.encryptOtherTopic(ctx, userID, otherTopic)
+		if encryptErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save onboarding data"})
+			return
+		}
+		tmp := db.String(encrypted)
 		prismaOtherTopic = &tmp
 	}
 
@@ -267,14 +307,29 @@ func (h *OnboardingHandler) SubmitOnboarding(c *gin.Context) {
 		return
 	}
 
+	h.dispatcher.Fire(ctx, webhooks.EventUserOnboarded, userID)
+
 	// Perform circle matching
 	circle, isCritical, matchErr := h.matchingService.MatchUserToCircle(ctx, userID, topics, phq2Total, gad2Total)
 
+	if err := h.checkInPlanner.PlanCheckIns(ctx, userID, isCritical); err != nil {
+		log.Printf("Failed to plan check-ins for user %s: %v", userID, err)
+		// Don't fail the request - check-ins are a nice-to-have, not core onboarding
+	}
+
 	if isCritical {
 		// Send escalation email for critical risk
 		log.Printf("Sending critical risk alert for user %s", userID)
-		go h.emailService.SendCriticalRiskAlert(user.FullName, user.Email, phq2Total, gad2Total, topics)
+		go h.emailService.SendCriticalRiskAlert(user.FullName, user.Email, "Onboarding screening (PHQ-2/GAD-2)", phq2Total, gad2Total, topics)
 		// Don't fail the request if email fails
+
+		go h.notificationPlanner.Dispatch(context.Background(), notifier.Notification{
+			Event:  notifier.EventCriticalRiskAlert,
+			UserID: userID,
+			Data:   map[string]string{"UserName": user.FullName},
+		})
+
+		h.socketServer.BroadcastCrisisAlert(userID, phq2Total, gad2Total, now)
 	} else if matchErr != nil {
 		log.Printf("Circle matching failed for user %s: %v", userID, matchErr)
 		// Continue anyway - user can be matched manually later
@@ -288,6 +343,16 @@ func (h *OnboardingHandler) SubmitOnboarding(c *gin.Context) {
 
 		if err == nil {
 			go h.emailService.SendCircleMatchNotification(user.FullName, user.Email, circle.Name, moderator.FullName)
+
+			go h.notificationPlanner.Dispatch(context.Background(), notifier.Notification{
+				Event:  notifier.EventCircleMatched,
+				UserID: userID,
+				Data: map[string]string{
+					"UserName":      user.FullName,
+					"CircleName":    circle.Name,
+					"ModeratorName": moderator.FullName,
+				},
+			})
 		}
 	}
 
@@ -331,6 +396,124 @@ func (h *OnboardingHandler) SubmitOnboarding(c *gin.Context) {
 	})
 }
 
+// SubmitExtendedScreening handles the full PHQ-9/GAD-7 instrument,
+// triggered client-side once SubmitOnboarding's short-form PHQ-2/GAD-2
+// screen crosses the medium threshold in computeScreeningLevel. It layers
+// the remaining 7 PHQ and 5 GAD items onto the stored short-form answers,
+// computes the validated 0-27/0-21 totals and severity bands, and
+// re-runs circle matching with the more granular signal. The PHQ-9 item
+// 9 self-harm answer escalates on its own regardless of the totals.
+func (h *OnboardingHandler) SubmitExtendedScreening(c *gin.Context) {
+	role, _ := c.Get("role")
+	if role == "MODERATOR" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Moderators do not require onboarding"})
+		return
+	}
+
+	var req ExtendedScreeningRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if len(req.PhqItems) != 7 || len(req.GadItems) != 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please complete this field."})
+		return
+	}
+	for _, value := range req.PhqItems {
+		if !isValidScaleValue(value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Please complete this field."})
+			return
+		}
+	}
+	for _, value := range req.GadItems {
+		if !isValidScaleValue(value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Please complete this field."})
+			return
+		}
+	}
+
+	userIDValue, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDValue.(string)
+	ctx := context.Background()
+
+	onboardingRecord, err := h.client.OnboardingResponse.FindUnique(
+		db.OnboardingResponse.UserID.Equals(userID),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Complete the initial onboarding screening first"})
+		return
+	}
+	if onboardingRecord.ScreeningResult == db.ScreeningLevelLow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Extended screening is only available once the short-form screen crosses the medium threshold"})
+		return
+	}
+
+	phq9Total := onboardingRecord.Phq2Total + sumInts(req.PhqItems)
+	gad7Total := onboardingRecord.Gad2Total + sumInts(req.GadItems)
+	selfHarmItem := req.PhqItems[len(req.PhqItems)-1]
+
+	phq9Severity := phq9SeverityBand(phq9Total)
+	gad7Severity := gad7SeverityBand(gad7Total)
+
+	_, err = h.client.OnboardingResponse.FindUnique(
+		db.OnboardingResponse.UserID.Equals(userID),
+	).Update(
+		db.OnboardingResponse.Phq9Items.Set(req.PhqItems),
+		db.OnboardingResponse.Gad7Items.Set(req.GadItems),
+		db.OnboardingResponse.Phq9Total.Set(phq9Total),
+		db.OnboardingResponse.Gad7Total.Set(gad7Total),
+		db.OnboardingResponse.Phq9Severity.Set(phq9Severity),
+		db.OnboardingResponse.Gad7Severity.Set(gad7Severity),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save screening data"})
+		return
+	}
+
+	user, err := h.client.User.FindUnique(
+		db.User.ID.Equals(userID),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	circle, isCritical, matchErr := h.matchingService.MatchUserToCircleExtended(ctx, userID, onboardingRecord.Topics, phq9Total, gad7Total, selfHarmItem)
+
+	if isCritical {
+		log.Printf("Sending extended critical risk alert for user %s", userID)
+		go h.emailService.SendExtendedCriticalRiskAlert(user.FullName, user.Email, phq9Total, gad7Total, selfHarmItem, onboardingRecord.Topics)
+		// Don't fail the request if email fails
+
+		go h.notificationPlanner.Dispatch(context.Background(), notifier.Notification{
+			Event:  notifier.EventCriticalRiskAlert,
+			UserID: userID,
+			Data:   map[string]string{"UserName": user.FullName},
+		})
+
+		h.socketServer.BroadcastExtendedCrisisAlert(userID, phq9Total, gad7Total, selfHarmItem, time.Now().UTC())
+	} else if matchErr != nil {
+		log.Printf("Extended circle matching failed for user %s: %v", userID, matchErr)
+		// Continue anyway - user can be matched manually later
+	} else if circle != nil {
+		log.Printf("User %s re-matched to circle %s after extended screening", userID, circle.Name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": ExtendedScreeningResponsePayload{
+			Phq9Total:    phq9Total,
+			Gad7Total:    gad7Total,
+			Phq9Severity: phq9Severity,
+			Gad7Severity: gad7Severity,
+		},
+	})
+}
+
 func validateOnboardingRequest(req OnboardingRequest) string {
 	if len(req.Topics) == 0 {
 		return "Please complete this field."
@@ -382,6 +565,46 @@ func isValidScaleValue(value int) bool {
 	return value >= 0 && value <= 3
 }
 
+// phq9SeverityBand maps a PHQ-9 total (0-27) to its validated severity
+// band: none/mild/moderate/moderately-severe/severe.
+func phq9SeverityBand(total int) string {
+	switch {
+	case total >= 20:
+		return "severe"
+	case total >= 15:
+		return "moderately-severe"
+	case total >= 10:
+		return "moderate"
+	case total >= 5:
+		return "mild"
+	default:
+		return "none"
+	}
+}
+
+// gad7SeverityBand maps a GAD-7 total (0-21) to its validated severity
+// band: none/mild/moderate/severe.
+func gad7SeverityBand(total int) string {
+	switch {
+	case total >= 15:
+		return "severe"
+	case total >= 10:
+		return "moderate"
+	case total >= 5:
+		return "mild"
+	default:
+		return "none"
+	}
+}
+
+func sumInts(values []int) int {
+	total := 0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
 func computeScreeningLevel(phq2, gad2 int) db.ScreeningLevel {
 	maxScore := phq2
 	if gad2 > maxScore {
@@ -428,3 +651,22 @@ func containsOther(topics []string) bool {
 	}
 	return false
 }
+
+// encryptOtherTopic encrypts the user's free-text "Other" disclosure
+// before it's written to OnboardingResponse.otherTopic.
+func (h *OnboardingHandler) encryptOtherTopic(ctx context.Context, userID, plaintext string) (string, error) {
+	ciphertext, err := h.cipher.EncryptForUser(ctx, userID, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return crypto.Encode(ciphertext), nil
+}
+
+// decryptOtherTopic reverses encryptOtherTopic for display.
+func (h *OnboardingHandler) decryptOtherTopic(ctx context.Context, userID, encoded string) (string, error) {
+	ciphertext, err := crypto.Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	return h.cipher.DecryptForUser(ctx, userID, ciphertext)
+}
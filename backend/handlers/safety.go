@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"mindbridge/backend/prisma/db"
+)
+
+type SafetyHandler struct {
+	client *db.PrismaClient
+}
+
+func NewSafetyHandler(client *db.PrismaClient) *SafetyHandler {
+	return &SafetyHandler{client: client}
+}
+
+type SafetyIncidentResponse struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	Severity   string    `json:"severity"`
+	Categories []string  `json:"categories"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ListIncidents returns SafetyIncident rows the instant help safety
+// pipeline has written, filterable by severity and by how far back to
+// look, for the moderator dashboard's safety tab.
+func (h *SafetyHandler) ListIncidents(c *gin.Context) {
+	if !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can view safety incidents"})
+		return
+	}
+
+	filters := []db.SafetyIncidentWhereParam{}
+
+	if severity := c.Query("severity"); severity != "" {
+		filters = append(filters, db.SafetyIncident.Severity.Equals(severity))
+	}
+
+	if windowMinutes := c.Query("windowMinutes"); windowMinutes != "" {
+		minutes, err := strconv.Atoi(windowMinutes)
+		if err != nil || minutes <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "windowMinutes must be a positive integer"})
+			return
+		}
+		since := time.Now().Add(-time.Duration(minutes) * time.Minute)
+		filters = append(filters, db.SafetyIncident.CreatedAt.Gte(since))
+	}
+
+	ctx := context.Background()
+	incidents, err := h.client.SafetyIncident.FindMany(filters...).OrderBy(
+		db.SafetyIncident.CreatedAt.Order(db.DESC),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load safety incidents"})
+		return
+	}
+
+	results := make([]SafetyIncidentResponse, len(incidents))
+	for i, incident := range incidents {
+		results[i] = SafetyIncidentResponse{
+			ID:         incident.ID,
+			UserID:     incident.UserID,
+			Severity:   incident.Severity,
+			Categories: incident.Categories,
+			Reason:     incident.Reason,
+			CreatedAt:  incident.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/webhooks"
+)
+
+type WebhookHandler struct {
+	client     *db.PrismaClient
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(client *db.PrismaClient, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		client:     client,
+		dispatcher: dispatcher,
+	}
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	// Kind selects the delivery format: "generic" (default) sends the
+	// canonical signed JSON event; "telegram" and "discord" reformat it
+	// into each platform's own message shape, since those endpoints
+	// expect bot/webhook payloads rather than a signed JSON body they'd
+	// verify themselves.
+	Kind string `json:"kind"`
+}
+
+var validWebhookKinds = map[string]db.WebhookKind{
+	"":         db.WebhookKindGeneric,
+	"generic":  db.WebhookKindGeneric,
+	"telegram": db.WebhookKindTelegram,
+	"discord":  db.WebhookKindDiscord,
+}
+
+// CreateWebhook registers an institution-owned endpoint that receives
+// signed event notifications (crisis.detected, circle.created,
+// circle.matched, message.created, risk.critical,
+// instant_help.escalated, user.onboarded) instead of being given direct
+// DB access.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	if !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can manage webhooks"})
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	kind, ok := validWebhookKinds[req.Kind]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be one of generic, telegram, discord"})
+		return
+	}
+
+	ctx := context.Background()
+	hook, err := h.client.Webhook.CreateOne(
+		db.Webhook.URL.Set(req.URL),
+		db.Webhook.Secret.Set(req.Secret),
+		db.Webhook.Events.Set(req.Events),
+		db.Webhook.Active.Set(true),
+		db.Webhook.Kind.Set(kind),
+	).Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":     hook.ID,
+		"url":    hook.URL,
+		"events": hook.Events,
+		"active": hook.Active,
+		"kind":   hook.Kind,
+	})
+}
+
+// ListWebhooks returns every webhook registered for the institution.
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	if !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can manage webhooks"})
+		return
+	}
+
+	ctx := context.Background()
+	hooks, err := h.client.Webhook.FindMany().Exec(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load webhooks"})
+		return
+	}
+
+	results := make([]gin.H, len(hooks))
+	for i, hook := range hooks {
+		results[i] = gin.H{
+			"id":     hook.ID,
+			"url":    hook.URL,
+			"events": hook.Events,
+			"active": hook.Active,
+			"kind":   hook.Kind,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
+
+// DeleteWebhook removes a registered endpoint.
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can manage webhooks"})
+		return
+	}
+
+	id := c.Param("id")
+	ctx := context.Background()
+
+	if _, err := h.client.Webhook.FindUnique(
+		db.Webhook.ID.Equals(id),
+	).Delete().Exec(ctx); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// TestWebhook fires a canned event at the endpoint so campus counseling IT
+// can validate their integration without waiting for a real crisis alert.
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	if !isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can manage webhooks"})
+		return
+	}
+
+	id := c.Param("id")
+	ctx := context.Background()
+
+	if err := h.dispatcher.FireTest(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test event queued"})
+}
+
+// isModerator reports whether the authenticated request belongs to a
+// moderator, the only role allowed to manage institution-level webhooks.
+func isModerator(c *gin.Context) bool {
+	role, _ := c.Get("role")
+	return role == "MODERATOR"
+}
@@ -4,14 +4,23 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"mindbridge/backend/bridge/matrix"
+	"mindbridge/backend/crypto"
 	"mindbridge/backend/handlers"
 	"mindbridge/backend/middleware"
+	"mindbridge/backend/notifier"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
+	"mindbridge/backend/storage"
+	"mindbridge/backend/tor"
+	"mindbridge/backend/utils"
+	"mindbridge/backend/webhooks"
 	"mindbridge/backend/websocket"
 )
 
@@ -28,6 +37,12 @@ func main() {
 	}
 	gin.SetMode(mode)
 
+	// Get port from environment or use default
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
 	// Initialize Prisma client
 	client := db.NewClient()
 	if err := client.Prisma.Connect(); err != nil {
@@ -41,16 +56,71 @@ func main() {
 
 	log.Println("Successfully connected to database")
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(client)
-	onboardingHandler := handlers.NewOnboardingHandler(client)
-	messageHandler := handlers.NewMessageHandler(client)
+	// Initialize field-level encryption
+	fieldCipher, err := crypto.NewFromEnv(client)
+	if err != nil {
+		log.Fatal("Failed to initialize field encryption:", err)
+	}
 
 	// Initialize WebSocket server
-	socketServer, err := websocket.NewSocketServer(client)
+	socketServer, err := websocket.NewSocketServer(client, fieldCipher)
 	if err != nil {
 		log.Fatal("Failed to create socket server:", err)
 	}
+
+	// Initialize webhook dispatcher
+	webhookDispatcher := webhooks.NewDispatcher(client)
+	socketServer.SetWebhookDispatcher(webhookDispatcher)
+
+	// Initialize the optional Matrix bridge. It's a no-op at the call
+	// sites when unconfigured, so it's always safe to construct.
+	matrixConfig := matrix.NewConfigFromEnv()
+	var matrixBridgeHandler *handlers.BridgeMatrixHandler
+	if matrixConfig.Enabled() {
+		matrixClient := matrix.NewClient(matrixConfig)
+		matrixBridge := matrix.NewBridge(matrixConfig, matrixClient, client)
+		socketServer.SetMatrixBridge(matrixBridge)
+		matrixBridgeHandler = handlers.NewBridgeMatrixHandler(client, matrixBridge, matrixConfig)
+		log.Println("Matrix bridge enabled for", matrixConfig.ServerName)
+	}
+
+	// Initialize the multi-channel notification planner and the outbox
+	// worker that retries whatever it fails to send.
+	notificationPlanner, err := notifier.NewNotificationPlanner(
+		client,
+		"./templates/notifications",
+		notifier.NewEmailChannel(),
+		notifier.NewInAppChannel(socketServer),
+		notifier.NewPushChannel(),
+		notifier.NewTelegramChannel(),
+		notifier.NewDiscordChannel(),
+	)
+	if err != nil {
+		log.Fatal("Failed to initialize notification planner:", err)
+	}
+	outboxWorker := notifier.NewOutboxWorker(notificationPlanner)
+	outboxCtx, stopOutboxWorker := context.WithCancel(context.Background())
+	defer stopOutboxWorker()
+	go outboxWorker.Start(outboxCtx)
+
+	// Initialize message image storage - an S3-compatible bucket when
+	// STORAGE_PROVIDER=s3, local disk otherwise so dev doesn't need bucket
+	// credentials to run.
+	messageImageStore, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatal("Failed to initialize message image storage:", err)
+	}
+
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(client, fieldCipher)
+	onboardingHandler := handlers.NewOnboardingHandler(client, fieldCipher, socketServer, webhookDispatcher, notificationPlanner)
+	messageHandler := handlers.NewMessageHandler(client, fieldCipher, messageImageStore)
+	circleHandler := handlers.NewCircleHandler(client, webhookDispatcher)
+	instantHelpHandler := handlers.NewInstantHelpHandler(client, os.Getenv("GEMINI_API_KEY"), webhookDispatcher)
+	webhookHandler := handlers.NewWebhookHandler(client, webhookDispatcher)
+	keysHandler := handlers.NewKeysHandler(client, services.NewE2EEService(client))
+	safetyHandler := handlers.NewSafetyHandler(client)
+
 	go func() {
 		if err := socketServer.GetServer().Serve(); err != nil {
 			log.Fatalf("Socket.io server error: %s\n", err)
@@ -59,6 +129,32 @@ func main() {
 	defer socketServer.GetServer().Close()
 	log.Println("Socket.io server initialized")
 
+	// Start the scheduled check-in notifier
+	checkInSender := notifier.NewSender(services.NewGeminiService(os.Getenv("GEMINI_API_KEY")), socketServer)
+	checkInScheduler := notifier.NewScheduler(client, checkInSender)
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go checkInScheduler.Start(schedulerCtx)
+	log.Println("Check-in notifier started")
+
+	// Start the onion service, if configured. NewService blocks until the
+	// descriptor publishes (or the attempt times out), so onionAddress is
+	// settled before the health endpoint below can ever be hit.
+	var onionAddress string
+	torConfig := tor.NewConfigFromEnv()
+	if torConfig.Enabled {
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			log.Printf("Invalid PORT for onion service: %v", err)
+		} else if torService, err := tor.NewService(context.Background(), torConfig, portNum); err != nil {
+			log.Printf("Failed to start onion service: %v", err)
+		} else {
+			defer torService.Close()
+			onionAddress = torService.Address()
+			log.Printf("Onion service published at %s", onionAddress)
+		}
+	}
+
 	// Initialize router
 	router := gin.Default()
 
@@ -75,11 +171,27 @@ func main() {
 
 	// Health check endpoint
 	router.GET("/api/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"message": "MindBridge API is running",
+		health := gin.H{
+			"status":    "ok",
+			"message":   "MindBridge API is running",
 			"timestamp": time.Now().Unix(),
-		})
+		}
+		if onionAddress != "" {
+			health["onionAddress"] = onionAddress
+		}
+		c.JSON(200, health)
+	})
+
+	// JWKS endpoint - lets other services (e.g. a future moderator
+	// dashboard) verify a MindBridge-issued JWT without the shared
+	// secret, by fetching the signing key named in the token's kid.
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		jwks, err := utils.JWKS()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to load signing keys"})
+			return
+		}
+		c.JSON(200, jwks)
 	})
 
 	// API routes group
@@ -93,6 +205,17 @@ func main() {
 			auth.GET("/check-email", authHandler.CheckEmail)
 			auth.GET("/check-phone-number", authHandler.CheckPhoneNumber)
 			auth.GET("/me", middleware.AuthMiddleware(), authHandler.GetMe)
+			auth.POST("/password-reset", authHandler.RequestPasswordReset)
+			auth.POST("/password-reset/confirm", authHandler.ConfirmPasswordReset)
+			auth.POST("/email-verification", middleware.AuthMiddleware(), authHandler.RequestEmailVerification)
+			auth.POST("/email-verification/confirm", authHandler.ConfirmEmailVerification)
+		}
+
+		// Current-user routes (protected)
+		me := api.Group("/me")
+		me.Use(middleware.AuthMiddleware())
+		{
+			me.PATCH("/notification-preferences", authHandler.UpdateNotificationPreferences)
 		}
 
 		// Onboarding routes (protected)
@@ -101,6 +224,26 @@ func main() {
 		{
 			onboarding.GET("", onboardingHandler.GetOnboarding)
 			onboarding.POST("", onboardingHandler.SubmitOnboarding)
+			onboarding.POST("/screening/extended", onboardingHandler.SubmitExtendedScreening)
+		}
+
+		// Circle routes (protected)
+		circles := api.Group("/circles")
+		circles.Use(middleware.AuthMiddleware())
+		{
+			circles.POST("/:id/invites", circleHandler.CreateInvite)
+			circles.POST("/join", circleHandler.JoinCircle)
+			circles.PATCH("/:id/settings", circleHandler.UpdateSettings)
+		}
+
+		// Key bundle routes (protected) - publishing/fetching the
+		// long-lived identity and signing keys E2EE circles seal chain
+		// keys to.
+		keys := api.Group("/keys")
+		keys.Use(middleware.AuthMiddleware())
+		{
+			keys.POST("/bundle", keysHandler.UploadBundle)
+			keys.GET("/bundle/:userId", keysHandler.GetBundle)
 		}
 
 		// Message routes (protected)
@@ -111,6 +254,42 @@ func main() {
 			messages.GET("/circle/:circleId/members", messageHandler.GetCircleMembers)
 			messages.GET("/my-circle", messageHandler.GetUserCircle)
 			messages.POST("/upload", messageHandler.UploadImage)
+			messages.GET("/image/:id", messageHandler.GetImage)
+		}
+
+		// Webhook routes (moderator-only)
+		webhookRoutes := api.Group("/webhooks")
+		webhookRoutes.Use(middleware.AuthMiddleware())
+		{
+			webhookRoutes.GET("", webhookHandler.ListWebhooks)
+			webhookRoutes.POST("", webhookHandler.CreateWebhook)
+			webhookRoutes.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhookRoutes.POST("/:id/test", webhookHandler.TestWebhook)
+		}
+
+		// Admin routes (moderator-only)
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware())
+		{
+			admin.GET("/safety/incidents", safetyHandler.ListIncidents)
+		}
+
+		// Matrix bridge routes
+		if matrixBridgeHandler != nil {
+			bridgeMatrix := api.Group("/bridge/matrix")
+			{
+				bridgeMatrix.POST("/link", middleware.AuthMiddleware(), matrixBridgeHandler.CreateLinkCode)
+				bridgeMatrix.PUT("/transactions/:txnId", matrixBridgeHandler.Transactions)
+			}
+		}
+
+		// Instant help routes (protected)
+		instantHelp := api.Group("/instant-help")
+		instantHelp.Use(middleware.AuthMiddleware())
+		{
+			instantHelp.POST("/chat", instantHelpHandler.Chat)
+			instantHelp.POST("/stream", instantHelpHandler.ChatStream)
+			instantHelp.GET("/history", instantHelpHandler.GetHistory)
 		}
 
 		// Test endpoints
@@ -144,12 +323,6 @@ func main() {
 	// Serve uploaded files
 	router.Static("/uploads", "./uploads")
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
 	log.Printf("Starting MindBridge API server on port %s...", port)
 	if err := router.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
@@ -0,0 +1,180 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"mindbridge/backend/email"
+	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/websocket"
+)
+
+const (
+	channelEmail    = "email"
+	channelInApp    = "in_app"
+	channelPush     = "push"
+	channelTelegram = "telegram"
+	channelDiscord  = "discord"
+)
+
+// EmailChannel delivers a rendered notification over the same email
+// transport services.EmailService uses.
+type EmailChannel struct {
+	sender email.Sender
+}
+
+func NewEmailChannel() *EmailChannel {
+	return &EmailChannel{sender: email.NewFromEnv()}
+}
+
+func (c *EmailChannel) Name() string { return channelEmail }
+
+func (c *EmailChannel) Enabled(user *db.UserModel) bool { return user.NotifyEmail }
+
+func (c *EmailChannel) Send(ctx context.Context, user *db.UserModel, rendered RenderedContent) error {
+	return c.sender.Send(user.Email, rendered.Subject, rendered.Body)
+}
+
+// InAppChannel delivers a rendered notification as a toast to a user's
+// personal socket room, the same room BroadcastCheckIn uses.
+type InAppChannel struct {
+	socketServer *websocket.SocketServer
+}
+
+func NewInAppChannel(socketServer *websocket.SocketServer) *InAppChannel {
+	return &InAppChannel{socketServer: socketServer}
+}
+
+func (c *InAppChannel) Name() string { return channelInApp }
+
+func (c *InAppChannel) Enabled(user *db.UserModel) bool { return user.NotifyInApp }
+
+func (c *InAppChannel) Send(ctx context.Context, user *db.UserModel, rendered RenderedContent) error {
+	c.socketServer.BroadcastNotification(user.ID, rendered.Subject, rendered.Body)
+	return nil
+}
+
+// PushChannel delivers a Web Push notification through a VAPID-configured
+// push service. It returns an error - rather than silently no-opping - so
+// a send always lands in the outbox for OutboxWorker to retry, until
+// WEBPUSH_VAPID_PUBLIC_KEY/WEBPUSH_VAPID_PRIVATE_KEY are set; actually
+// delivering still needs a per-device subscription store this tree
+// doesn't have yet, so Enabled stays false until that lands too.
+type PushChannel struct {
+	privateKey string
+	publicKey  string
+}
+
+func NewPushChannel() *PushChannel {
+	return &PushChannel{
+		privateKey: os.Getenv("WEBPUSH_VAPID_PRIVATE_KEY"),
+		publicKey:  os.Getenv("WEBPUSH_VAPID_PUBLIC_KEY"),
+	}
+}
+
+func (c *PushChannel) Name() string { return channelPush }
+
+func (c *PushChannel) Enabled(user *db.UserModel) bool {
+	return false
+}
+
+func (c *PushChannel) Send(ctx context.Context, user *db.UserModel, rendered RenderedContent) error {
+	return fmt.Errorf("notifier: web push not yet implemented (no per-device subscription store)")
+}
+
+// TelegramChannel delivers a DM through a Telegram bot's sendMessage API
+// to the chat ID a user has linked via BotChatID, disabled until both
+// TELEGRAM_BOT_TOKEN is set and the user has linked one.
+type TelegramChannel struct {
+	endpoint string
+}
+
+func NewTelegramChannel() *TelegramChannel {
+	endpoint := ""
+	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
+		endpoint = "https://api.telegram.org/bot" + token + "/sendMessage"
+	}
+	return &TelegramChannel{endpoint: endpoint}
+}
+
+func (c *TelegramChannel) Name() string { return channelTelegram }
+
+func (c *TelegramChannel) Enabled(user *db.UserModel) bool {
+	chatID, ok := user.BotChatID()
+	return c.endpoint != "" && ok && chatID != ""
+}
+
+func (c *TelegramChannel) Send(ctx context.Context, user *db.UserModel, rendered RenderedContent) error {
+	chatID, _ := user.BotChatID()
+	form := url.Values{
+		"chat_id": {chatID},
+		"text":    {rendered.Subject + "\n\n" + rendered.Body},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("notifier: failed to build telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notifier: telegram request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notifier: telegram returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// DiscordChannel posts a rendered notification to a single shared Discord
+// incoming webhook. Discord's delivery model is per-channel webhooks, not
+// per-user DMs, so unlike TelegramChannel this isn't gated on anything
+// about the individual user - it's meant for events a moderator channel
+// should see (critical risk alerts, say), configured once via
+// DISCORD_WEBHOOK_URL.
+type DiscordChannel struct {
+	webhookURL string
+}
+
+func NewDiscordChannel() *DiscordChannel {
+	return &DiscordChannel{webhookURL: os.Getenv("DISCORD_WEBHOOK_URL")}
+}
+
+func (c *DiscordChannel) Name() string { return channelDiscord }
+
+func (c *DiscordChannel) Enabled(user *db.UserModel) bool { return c.webhookURL != "" }
+
+func (c *DiscordChannel) Send(ctx context.Context, user *db.UserModel, rendered RenderedContent) error {
+	body, err := json.Marshal(map[string]string{"content": rendered.Subject + "\n" + rendered.Body})
+	if err != nil {
+		return fmt.Errorf("notifier: failed to marshal discord payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notifier: failed to build discord request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notifier: discord request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notifier: discord webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
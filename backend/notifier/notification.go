@@ -0,0 +1,184 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// Event names a trigger a NotificationPlanner resolves into one or more
+// channel deliveries. Unlike the check-in cadence above, these fire
+// immediately off an application event instead of on a schedule.
+type Event string
+
+const (
+	EventCriticalRiskAlert Event = "critical_risk_alert"
+	EventCircleMatched     Event = "circle_matched"
+	EventNewMessage        Event = "new_message"
+	EventModeratorMention  Event = "moderator_mention"
+)
+
+// throttleWindows bounds how often the same event can reach the same
+// user, so a burst of triggers (several flagged messages in a row, say)
+// doesn't turn into a wall of duplicate alerts. An event with no entry
+// isn't throttled at all.
+var throttleWindows = map[Event]time.Duration{
+	EventCriticalRiskAlert: 15 * time.Minute,
+}
+
+// quietHoursExempt lists events urgent enough to ignore a user's quiet
+// hours preference and every other channel still gets tried.
+var quietHoursExempt = map[Event]bool{
+	EventCriticalRiskAlert: true,
+}
+
+// Notification is what a caller hands to NotificationPlanner.Dispatch: who
+// it's for, which event fired, and whatever that event's template needs
+// to fill in its subject/body fragments.
+type Notification struct {
+	Event  Event
+	UserID string
+	Data   map[string]string
+}
+
+// RenderedContent is one event's subject/body after its template has been
+// executed against a Notification's Data.
+type RenderedContent struct {
+	Subject string
+	Body    string
+}
+
+// NotificationChannel is one delivery mechanism a NotificationPlanner can
+// send through - email, an in-app toast, web push, or a chat bot. Enabled
+// decides whether a given user can currently be reached on this channel at
+// all (opted in, and the channel itself configured); Send only has to get
+// rendered content to them.
+type NotificationChannel interface {
+	Name() string
+	Enabled(user *db.UserModel) bool
+	Send(ctx context.Context, user *db.UserModel, rendered RenderedContent) error
+}
+
+// NotificationPlanner resolves an Event against a user's preferences,
+// quiet hours, and recent delivery history, renders the event's template,
+// and dispatches it to whichever channels apply. Every attempt is
+// persisted to NotificationOutbox first, so a channel failure is retried
+// with backoff by OutboxWorker instead of silently dropped.
+type NotificationPlanner struct {
+	client    *db.PrismaClient
+	templates *templateRegistry
+	channels  []NotificationChannel
+}
+
+// NewNotificationPlanner builds a NotificationPlanner with channels tried
+// in the order given. templateDir is read once, at startup - see
+// loadTemplateRegistry.
+func NewNotificationPlanner(client *db.PrismaClient, templateDir string, channels ...NotificationChannel) (*NotificationPlanner, error) {
+	templates, err := loadTemplateRegistry(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationPlanner{client: client, templates: templates, channels: channels}, nil
+}
+
+// Dispatch resolves n against userID's preferences and recent delivery
+// history, then sends it over every eligible channel. A channel failure
+// is recorded to the outbox rather than returned, since Dispatch is meant
+// to be fired with `go` from request-handling code that's already moved
+// on by the time delivery finishes.
+func (p *NotificationPlanner) Dispatch(ctx context.Context, n Notification) error {
+	user, err := p.client.User.FindUnique(db.User.ID.Equals(n.UserID)).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("notifier: failed to load user %s for %s: %w", n.UserID, n.Event, err)
+	}
+
+	throttled, err := p.isThrottled(ctx, n)
+	if err != nil {
+		log.Printf("notifier: failed to check throttle for %s/%s: %v", n.UserID, n.Event, err)
+	} else if throttled {
+		return nil
+	}
+
+	rendered, err := p.templates.Render(n.Event, defaultLocale, n.Data)
+	if err != nil {
+		return fmt.Errorf("notifier: failed to render %s for user %s: %w", n.Event, n.UserID, err)
+	}
+
+	quiet := inQuietHours(user, time.Now().UTC()) && !quietHoursExempt[n.Event]
+
+	for _, channel := range p.channels {
+		if quiet && channel.Name() != channelInApp {
+			continue
+		}
+		if !channel.Enabled(user) {
+			continue
+		}
+
+		outboxID, err := p.enqueue(ctx, n, channel.Name(), rendered)
+		if err != nil {
+			log.Printf("notifier: failed to enqueue %s/%s for user %s: %v", n.Event, channel.Name(), n.UserID, err)
+			continue
+		}
+
+		p.attempt(ctx, outboxID, channel, user, rendered)
+	}
+
+	return nil
+}
+
+// isThrottled reports whether n's event has a throttle window and a
+// recent outbox row for the same user/event already falls inside it.
+func (p *NotificationPlanner) isThrottled(ctx context.Context, n Notification) (bool, error) {
+	window, ok := throttleWindows[n.Event]
+	if !ok {
+		return false, nil
+	}
+
+	since := db.DateTime(time.Now().UTC().Add(-window))
+	recent, err := p.client.NotificationOutbox.FindMany(
+		db.NotificationOutbox.UserID.Equals(n.UserID),
+		db.NotificationOutbox.Event.Equals(string(n.Event)),
+		db.NotificationOutbox.CreatedAt.Gt(since),
+	).Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(recent) > 0, nil
+}
+
+// channelByName finds a registered channel by the name its Name() method
+// returns, used by OutboxWorker to resume a retry without knowing the
+// channel instance up front - only what was persisted to the outbox row.
+func (p *NotificationPlanner) channelByName(name string) NotificationChannel {
+	for _, channel := range p.channels {
+		if channel.Name() == name {
+			return channel
+		}
+	}
+	return nil
+}
+
+// inQuietHours reports whether now (UTC) falls inside user's configured
+// quiet hours window. Both bounds are plain hour-of-day (0-23, UTC) -
+// this tree has no per-user timezone field to convert against, the same
+// simplification the check-in Scheduler above makes. Unconfigured (either
+// bound absent) never counts as quiet.
+func inQuietHours(user *db.UserModel, now time.Time) bool {
+	start, startOk := user.QuietHoursStart()
+	end, endOk := user.QuietHoursEnd()
+	if !startOk || !endOk {
+		return false
+	}
+
+	hour := now.Hour()
+	if start <= end {
+		return hour >= start && hour < end
+	}
+	// Window wraps past midnight, e.g. 22 -> 7.
+	return hour >= start || hour < end
+}
@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// outboxPollInterval is how often OutboxWorker checks for retries that
+// have come due.
+const outboxPollInterval = 30 * time.Second
+
+// outboxBackoff is the redelivery schedule for a failed notification
+// send, the same shape as webhooks.retryBackoff - once it's exhausted the
+// row is left Failed instead of retried again.
+var outboxBackoff = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	1 * time.Hour,
+}
+
+// outboxPayload is what gets marshaled into NotificationOutbox.Payload, so
+// a retry has the already-rendered content to send without re-resolving
+// the template (user-facing copy shouldn't change mid-retry even if the
+// template file does).
+type outboxPayload struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// enqueue writes a Pending NotificationOutbox row for one channel delivery
+// of n, so attempt always has a persisted record to update rather than
+// risking a send that succeeds or fails with nothing to show for it.
+func (p *NotificationPlanner) enqueue(ctx context.Context, n Notification, channel string, rendered RenderedContent) (string, error) {
+	payload, err := json.Marshal(outboxPayload{Subject: rendered.Subject, Body: rendered.Body})
+	if err != nil {
+		return "", err
+	}
+
+	row, err := p.client.NotificationOutbox.CreateOne(
+		db.NotificationOutbox.User.Link(db.User.ID.Equals(n.UserID)),
+		db.NotificationOutbox.Event.Set(string(n.Event)),
+		db.NotificationOutbox.Channel.Set(channel),
+		db.NotificationOutbox.Payload.Set(string(payload)),
+		db.NotificationOutbox.Status.Set(db.NotificationOutboxStatusPending),
+		db.NotificationOutbox.NextAttemptAt.Set(db.DateTime(time.Now().UTC())),
+	).Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return row.ID, nil
+}
+
+// attempt claims outboxID by flipping it to Processing, then sends
+// through channel - the same claim-before-work idiom Scheduler uses, so a
+// concurrent OutboxWorker poll never double-sends a row this call is
+// already handling.
+func (p *NotificationPlanner) attempt(ctx context.Context, outboxID string, channel NotificationChannel, user *db.UserModel, rendered RenderedContent) {
+	if _, err := p.client.NotificationOutbox.FindUnique(
+		db.NotificationOutbox.ID.Equals(outboxID),
+	).Update(
+		db.NotificationOutbox.Status.Set(db.NotificationOutboxStatusProcessing),
+	).Exec(ctx); err != nil {
+		log.Printf("notifier: failed to claim outbox row %s: %v", outboxID, err)
+		return
+	}
+
+	if err := channel.Send(ctx, user, rendered); err != nil {
+		log.Printf("notifier: %s delivery failed for outbox row %s: %v", channel.Name(), outboxID, err)
+		p.scheduleRetry(ctx, outboxID, 0)
+		return
+	}
+
+	if _, err := p.client.NotificationOutbox.FindUnique(
+		db.NotificationOutbox.ID.Equals(outboxID),
+	).Update(
+		db.NotificationOutbox.Status.Set(db.NotificationOutboxStatusSent),
+	).Exec(ctx); err != nil {
+		log.Printf("notifier: failed to mark outbox row %s sent: %v", outboxID, err)
+	}
+}
+
+// scheduleRetry moves outboxID back to Pending with NextAttemptAt pushed
+// out by outboxBackoff[attempt], or to Failed once attempt exhausts it.
+func (p *NotificationPlanner) scheduleRetry(ctx context.Context, outboxID string, attempt int) {
+	status := db.NotificationOutboxStatusPending
+	nextAttempt := time.Now().UTC()
+	if attempt >= len(outboxBackoff) {
+		status = db.NotificationOutboxStatusFailed
+	} else {
+		nextAttempt = nextAttempt.Add(outboxBackoff[attempt])
+	}
+
+	if _, err := p.client.NotificationOutbox.FindUnique(
+		db.NotificationOutbox.ID.Equals(outboxID),
+	).Update(
+		db.NotificationOutbox.Status.Set(status),
+		db.NotificationOutbox.Attempts.Set(attempt+1),
+		db.NotificationOutbox.NextAttemptAt.Set(db.DateTime(nextAttempt)),
+	).Exec(ctx); err != nil {
+		log.Printf("notifier: failed to schedule retry for outbox row %s: %v", outboxID, err)
+	}
+}
+
+// OutboxWorker polls NotificationOutbox for Pending rows whose
+// NextAttemptAt has come due and retries them, so a transient channel
+// failure (an SMTP timeout, a rate-limited bot API) eventually gets
+// delivered instead of being lost with the original request that
+// triggered it.
+type OutboxWorker struct {
+	planner *NotificationPlanner
+}
+
+func NewOutboxWorker(planner *NotificationPlanner) *OutboxWorker {
+	return &OutboxWorker{planner: planner}
+}
+
+// Start ticks every outboxPollInterval until ctx is canceled, retrying any
+// due rows. Meant to be run in its own goroutine.
+func (w *OutboxWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.retryDue(ctx)
+		}
+	}
+}
+
+func (w *OutboxWorker) retryDue(ctx context.Context) {
+	due, err := w.planner.client.NotificationOutbox.FindMany(
+		db.NotificationOutbox.Status.Equals(db.NotificationOutboxStatusPending),
+		db.NotificationOutbox.NextAttemptAt.Lte(db.DateTime(time.Now().UTC())),
+	).With(
+		db.NotificationOutbox.User.Fetch(),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("notifier: failed to load due outbox retries: %v", err)
+		return
+	}
+
+	for i := range due {
+		w.retryOne(ctx, &due[i])
+	}
+}
+
+func (w *OutboxWorker) retryOne(ctx context.Context, row *db.NotificationOutboxModel) {
+	channel := w.planner.channelByName(row.Channel)
+	if channel == nil {
+		log.Printf("notifier: outbox row %s references unknown channel %q, marking failed", row.ID, row.Channel)
+		if _, err := w.planner.client.NotificationOutbox.FindUnique(
+			db.NotificationOutbox.ID.Equals(row.ID),
+		).Update(
+			db.NotificationOutbox.Status.Set(db.NotificationOutboxStatusFailed),
+		).Exec(ctx); err != nil {
+			log.Printf("notifier: failed to fail outbox row %s: %v", row.ID, err)
+		}
+		return
+	}
+
+	var payload outboxPayload
+	if err := json.Unmarshal([]byte(row.Payload), &payload); err != nil {
+		log.Printf("notifier: failed to unmarshal outbox row %s payload: %v", row.ID, err)
+		return
+	}
+
+	if _, err := w.planner.client.NotificationOutbox.FindUnique(
+		db.NotificationOutbox.ID.Equals(row.ID),
+	).Update(
+		db.NotificationOutbox.Status.Set(db.NotificationOutboxStatusProcessing),
+	).Exec(ctx); err != nil {
+		log.Printf("notifier: failed to claim outbox retry %s: %v", row.ID, err)
+		return
+	}
+
+	user := row.User()
+	rendered := RenderedContent{Subject: payload.Subject, Body: payload.Body}
+
+	if err := channel.Send(ctx, &user, rendered); err != nil {
+		log.Printf("notifier: retry %d for outbox row %s failed: %v", row.Attempts+1, row.ID, err)
+		w.planner.scheduleRetry(ctx, row.ID, row.Attempts+1)
+		return
+	}
+
+	if _, err := w.planner.client.NotificationOutbox.FindUnique(
+		db.NotificationOutbox.ID.Equals(row.ID),
+	).Update(
+		db.NotificationOutbox.Status.Set(db.NotificationOutboxStatusSent),
+	).Exec(ctx); err != nil {
+		log.Printf("notifier: failed to mark outbox retry %s sent: %v", row.ID, err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package notifier plans and delivers scheduled wellness check-ins. A
+// Planner writes due dates to the ScheduledNotification table when a user
+// completes onboarding; a Scheduler goroutine polls that table and hands
+// due rows to a Sender, which generates the message and delivers it over
+// whichever channels the user has opted into.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// standardOffsets are the check-in delays every onboarded user gets.
+var standardOffsets = []time.Duration{
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	14 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+// criticalOffsets are additional, earlier check-ins planned on top of
+// standardOffsets for a user who was flagged critical risk during
+// onboarding, since they need to be heard from sooner.
+var criticalOffsets = []time.Duration{
+	2 * time.Hour,
+	24 * time.Hour,
+}
+
+// Planner writes the ScheduledNotification rows a Scheduler will later
+// pick up and dispatch.
+type Planner struct {
+	client *db.PrismaClient
+}
+
+func NewPlanner(client *db.PrismaClient) *Planner {
+	return &Planner{client: client}
+}
+
+// PlanCheckIns schedules the standard check-in cadence for userID, plus
+// the additional early check-ins if isCritical is true. It's called once,
+// right after a user successfully completes onboarding.
+func (p *Planner) PlanCheckIns(ctx context.Context, userID string, isCritical bool) error {
+	now := time.Now().UTC()
+
+	offsets := standardOffsets
+	if isCritical {
+		offsets = append(append([]time.Duration{}, criticalOffsets...), standardOffsets...)
+	}
+
+	for _, offset := range offsets {
+		dueAt := db.DateTime(now.Add(offset))
+		_, err := p.client.ScheduledNotification.CreateOne(
+			db.ScheduledNotification.User.Link(db.User.ID.Equals(userID)),
+			db.ScheduledNotification.DueAt.Set(dueAt),
+			db.ScheduledNotification.Status.Set(db.ScheduledNotificationStatusPending),
+		).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("notifier: failed to schedule check-in for user %s: %w", userID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// pollInterval is how often the scheduler checks for due check-ins.
+const pollInterval = 60 * time.Second
+
+// Scheduler polls ScheduledNotification for due rows and hands each to a
+// Sender. Prisma has no SELECT ... FOR UPDATE SKIP LOCKED of its own, so a
+// row is "claimed" by transitioning it from pending to processing first;
+// if that update affects no rows, another poll already claimed it.
+type Scheduler struct {
+	client *db.PrismaClient
+	sender *Sender
+}
+
+func NewScheduler(client *db.PrismaClient, sender *Sender) *Scheduler {
+	return &Scheduler{client: client, sender: sender}
+}
+
+// Start ticks every pollInterval until ctx is canceled, dispatching any
+// check-ins that have come due. It's meant to be run in its own goroutine.
+func (sch *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sch.dispatchDue(ctx)
+		}
+	}
+}
+
+func (sch *Scheduler) dispatchDue(ctx context.Context) {
+	pending, err := sch.client.ScheduledNotification.FindMany(
+		db.ScheduledNotification.Status.Equals(db.ScheduledNotificationStatusPending),
+	).With(
+		db.ScheduledNotification.User.Fetch(),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("notifier: failed to load pending check-ins: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for i := range pending {
+		if now.Before(time.Time(pending[i].DueAt)) {
+			continue
+		}
+		sch.dispatchOne(ctx, &pending[i])
+	}
+}
+
+func (sch *Scheduler) dispatchOne(ctx context.Context, notification *db.ScheduledNotificationModel) {
+	user := notification.User()
+
+	// Claim the row by flipping it to processing before doing any work, so
+	// a second poll tick landing while this send is in flight won't also
+	// pick it up - dispatchDue only ever selects rows still in Pending.
+	_, err := sch.client.ScheduledNotification.FindUnique(
+		db.ScheduledNotification.ID.Equals(notification.ID),
+	).Update(
+		db.ScheduledNotification.Status.Set(db.ScheduledNotificationStatusProcessing),
+	).Exec(ctx)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return
+		}
+		log.Printf("notifier: failed to claim check-in %s: %v", notification.ID, err)
+		return
+	}
+
+	finalStatus := db.ScheduledNotificationStatusSent
+	if err := sch.sender.Send(&user); err != nil {
+		log.Printf("notifier: failed to send check-in %s: %v", notification.ID, err)
+		finalStatus = db.ScheduledNotificationStatusFailed
+	}
+
+	if _, err := sch.client.ScheduledNotification.FindUnique(
+		db.ScheduledNotification.ID.Equals(notification.ID),
+	).Update(
+		db.ScheduledNotification.Status.Set(finalStatus),
+	).Exec(ctx); err != nil {
+		log.Printf("notifier: failed to update check-in %s status: %v", notification.ID, err)
+	}
+}
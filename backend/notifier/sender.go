@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+
+	"mindbridge/backend/email"
+	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
+	"mindbridge/backend/sms"
+	"mindbridge/backend/websocket"
+)
+
+// crisisDeepLink is appended to every check-in message so a user who's
+// struggling can reach the crisis flow in one tap instead of navigating
+// the app.
+const crisisDeepLink = "http://localhost:5173/instant-help?crisis=1"
+
+// Sender generates a check-in message via GeminiService and delivers it
+// over whichever channels a user hasn't opted out of.
+type Sender struct {
+	geminiService *services.GeminiService
+	emailSender   email.Sender
+	smsSender     sms.Sender
+	socketServer  *websocket.SocketServer
+}
+
+func NewSender(geminiService *services.GeminiService, socketServer *websocket.SocketServer) *Sender {
+	return &Sender{
+		geminiService: geminiService,
+		emailSender:   email.NewFromEnv(),
+		smsSender:     sms.NewFromEnv(),
+		socketServer:  socketServer,
+	}
+}
+
+// Send generates a check-in message for user and delivers it over email,
+// SMS, and in-app, skipping any channel the user has opted out of.
+func (s *Sender) Send(user *db.UserModel) error {
+	message, err := s.geminiService.GenerateCheckIn(firstName(user.FullName))
+	if err != nil {
+		return fmt.Errorf("notifier: failed to generate check-in message: %w", err)
+	}
+
+	body := message + "\n\nNeed to talk to someone now? " + crisisDeepLink
+
+	if user.NotifyEmail {
+		if err := s.emailSender.Send(user.Email, "Checking in", body); err != nil {
+			log.Printf("notifier: email check-in failed for user %s: %v", user.ID, err)
+		}
+	}
+
+	if user.NotifySms {
+		if err := s.smsSender.Send(user.PhoneNumber, body); err != nil {
+			log.Printf("notifier: sms check-in failed for user %s: %v", user.ID, err)
+		}
+	}
+
+	if user.NotifyInApp {
+		s.socketServer.BroadcastCheckIn(user.ID, message, crisisDeepLink)
+	}
+
+	return nil
+}
+
+// firstName returns the portion of fullName before the first space, so a
+// check-in message can address the user by first name only.
+func firstName(fullName string) string {
+	for i, r := range fullName {
+		if r == ' ' {
+			return fullName[:i]
+		}
+	}
+	return fullName
+}
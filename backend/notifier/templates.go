@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// defaultLocale is used whenever a more specific locale has no template of
+// its own registered.
+const defaultLocale = "en"
+
+// templateRegistry loads templates/notifications/<event>.<locale>.tmpl
+// files once at startup, each holding a "subject" and "body" template
+// block in {{define}} sections, and renders them on demand.
+type templateRegistry struct {
+	templates map[string]*template.Template // keyed by "<event>.<locale>"
+}
+
+// loadTemplateRegistry parses every *.tmpl file in dir. A template file is
+// named <event>.<locale>.tmpl, e.g. critical_risk_alert.en.tmpl.
+func loadTemplateRegistry(dir string) (*templateRegistry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("notifier: failed to read template directory %s: %w", dir, err)
+	}
+
+	reg := &templateRegistry{templates: map[string]*template.Template{}}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".tmpl" {
+			continue
+		}
+
+		key := strings.TrimSuffix(entry.Name(), ".tmpl")
+		tmpl, err := template.ParseFiles(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("notifier: failed to parse template %s: %w", entry.Name(), err)
+		}
+
+		reg.templates[key] = tmpl
+	}
+
+	return reg, nil
+}
+
+// Render looks up event's template for locale, falling back to
+// defaultLocale, and executes its "subject" and "body" blocks against
+// data.
+func (r *templateRegistry) Render(event Event, locale string, data map[string]string) (RenderedContent, error) {
+	tmpl, ok := r.templates[string(event)+"."+locale]
+	if !ok {
+		tmpl, ok = r.templates[string(event)+"."+defaultLocale]
+	}
+	if !ok {
+		return RenderedContent{}, fmt.Errorf("notifier: no template registered for event %s", event)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return RenderedContent{}, fmt.Errorf("notifier: failed to render subject for %s: %w", event, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return RenderedContent{}, fmt.Errorf("notifier: failed to render body for %s: %w", event, err)
+	}
+
+	return RenderedContent{Subject: subject.String(), Body: body.String()}, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
 )
 
 func resetDatabase() {
@@ -57,6 +58,8 @@ func resetDatabase() {
 	// Create moderators
 	log.Println("\nCreating moderator accounts...")
 
+	emailService := services.NewEmailService()
+
 	moderators := []struct {
 		Email    string
 		Password string
@@ -102,8 +105,13 @@ func resetDatabase() {
 
 		if err != nil {
 			log.Printf("Failed to create moderator %s: %v", mod.Email, err)
-		} else {
-			log.Printf("✅ Created moderator: %s (ID: %s)", mod.FullName, user.ID)
+			continue
+		}
+
+		log.Printf("✅ Created moderator: %s (ID: %s)", mod.FullName, user.ID)
+
+		if err := emailService.SendModeratorInviteEmail(mod.FullName, mod.Email, mod.Password); err != nil {
+			log.Printf("Failed to send invite email to %s: %v", mod.Email, err)
 		}
 	}
 
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"os"
+
+	"mindbridge/backend/crypto"
+	"mindbridge/backend/prisma/db"
+)
+
+// rotateKeys re-wraps every user's data encryption key under a new master
+// KEK. It never touches the DEKs themselves (and therefore never
+// re-encrypts any Message or OnboardingResponse data) - only the wrapping
+// layer changes, so this is safe to run against a live database.
+//
+// Usage: MASTER_KEK=<old base64 key> MASTER_KEK_NEW=<new base64 key> go run rotate_keys.go
+func rotateKeys() {
+	oldKEKEncoded := os.Getenv("MASTER_KEK")
+	newKEKEncoded := os.Getenv("MASTER_KEK_NEW")
+	if oldKEKEncoded == "" || newKEKEncoded == "" {
+		log.Fatal("MASTER_KEK and MASTER_KEK_NEW must both be set")
+	}
+
+	oldKEK, err := base64.StdEncoding.DecodeString(oldKEKEncoded)
+	if err != nil {
+		log.Fatalf("Failed to decode MASTER_KEK: %v", err)
+	}
+	newKEK, err := base64.StdEncoding.DecodeString(newKEKEncoded)
+	if err != nil {
+		log.Fatalf("Failed to decode MASTER_KEK_NEW: %v", err)
+	}
+
+	client := db.NewClient()
+	if err := client.Prisma.Connect(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer func() {
+		if err := client.Prisma.Disconnect(); err != nil {
+			log.Printf("Failed to disconnect: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+
+	users, err := client.User.FindMany().Exec(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list users: %v", err)
+	}
+
+	rotated, skipped := 0, 0
+	for _, user := range users {
+		encoded, ok := user.DataEncryptionKey()
+		if !ok || encoded == "" {
+			skipped++
+			continue
+		}
+
+		wrapped, err := crypto.Decode(encoded)
+		if err != nil {
+			log.Printf("Skipping user %s: failed to decode wrapped key: %v", user.ID, err)
+			skipped++
+			continue
+		}
+
+		rewrapped, err := crypto.RewrapKey(oldKEK, newKEK, wrapped)
+		if err != nil {
+			log.Printf("Skipping user %s: failed to rewrap key: %v", user.ID, err)
+			skipped++
+			continue
+		}
+
+		if _, err := client.User.FindUnique(
+			db.User.ID.Equals(user.ID),
+		).Update(
+			db.User.DataEncryptionKey.Set(crypto.Encode(rewrapped)),
+		).Exec(ctx); err != nil {
+			log.Printf("Failed to persist rewrapped key for user %s: %v", user.ID, err)
+			skipped++
+			continue
+		}
+
+		rotated++
+	}
+
+	log.Printf("Key rotation complete: %d rotated, %d skipped", rotated, skipped)
+}
+
+func main() {
+	rotateKeys()
+}
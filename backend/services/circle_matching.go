@@ -2,19 +2,57 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
+	"time"
 
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/webhooks"
+)
+
+const (
+	inviteCodeLength      = 8
+	inviteCodeCharset     = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	inviteCodeTTL         = 7 * 24 * time.Hour
+	inviteMaxMemberExcess = 2 // hard cap: a code can push a circle to MaxMembers+2
 )
 
 type CircleMatchingService struct {
-	client *db.PrismaClient
+	client       *db.PrismaClient
+	dispatcher   *webhooks.Dispatcher
+	e2ee         *E2EEService
+	emailService *EmailService
 }
 
-func NewCircleMatchingService(client *db.PrismaClient) *CircleMatchingService {
-	return &CircleMatchingService{client: client}
+func NewCircleMatchingService(client *db.PrismaClient, dispatcher *webhooks.Dispatcher) *CircleMatchingService {
+	return &CircleMatchingService{
+		client:       client,
+		dispatcher:   dispatcher,
+		e2ee:         NewE2EEService(client),
+		emailService: NewEmailService(),
+	}
+}
+
+// crisisKeywords are the topic/message indicators that trigger the highest
+// priority crisis handling path across circle matching and live chat.
+var crisisKeywords = []string{"self", "harm", "suicide", "hopeless"}
+
+// crisisKeywordPattern matches crisisKeywords on word boundaries. A plain
+// substring check fires on ordinary supportive words that merely contain
+// one - "yourself", "myself", "selfless", "harmony", "harmless" - which is
+// exactly the kind of text a streamed model reply is full of.
+var crisisKeywordPattern = regexp.MustCompile(`(?i)\b(` + strings.Join(crisisKeywords, "|") + `)\b`)
+
+// ContainsCrisisKeyword reports whether text contains one of the crisis
+// indicators used by DetermineCircleCategory to route onboarding topics.
+// It's also used to interrupt a streaming model response if the model
+// starts drifting toward crisis content mid-reply.
+func ContainsCrisisKeyword(text string) bool {
+	return crisisKeywordPattern.MatchString(text)
 }
 
 // DetermineCircleCategory determines the appropriate circle category based on topics
@@ -26,12 +64,9 @@ func (s *CircleMatchingService) DetermineCircleCategory(topics []string) db.Circ
 	}
 
 	// Crisis indicators - highest priority
-	crisisKeywords := []string{"self", "harm", "suicide", "hopeless"}
 	for _, topic := range topicsLower {
-		for _, keyword := range crisisKeywords {
-			if strings.Contains(topic, keyword) {
-				return db.CircleCategoryCrisis
-			}
+		if crisisKeywordPattern.MatchString(topic) {
+			return db.CircleCategoryCrisis
 		}
 	}
 
@@ -90,6 +125,14 @@ func (s *CircleMatchingService) IsCriticalRisk(phq2Total, gad2Total int) bool {
 	return (phq2Total >= 5 && gad2Total >= 5) || phq2Total == 6 || gad2Total == 6
 }
 
+// IsCriticalRiskExtended applies the full PHQ-9/GAD-7 instrument's
+// thresholds: moderately-severe or severe on either total is an
+// automatic escalation, as is any non-zero answer to the PHQ-9 item 9
+// self-harm question regardless of what the totals come out to.
+func (s *CircleMatchingService) IsCriticalRiskExtended(phq9Total, gad7Total, selfHarmItem int) bool {
+	return selfHarmItem > 0 || phq9Total >= 15 || gad7Total >= 15
+}
+
 // FindOrCreateCircle finds an available circle or creates a new one
 func (s *CircleMatchingService) FindOrCreateCircle(ctx context.Context, category db.CircleCategory) (*db.CircleModel, error) {
 	// Try to find an active circle with space
@@ -132,6 +175,8 @@ func (s *CircleMatchingService) FindOrCreateCircle(ctx context.Context, category
 
 	log.Printf("Created new circle: %s (Category: %s, Moderator: %s)", newCircle.Name, category, moderator.FullName)
 
+	s.dispatcher.Fire(ctx, webhooks.EventCircleCreated, moderator.ID)
+
 	return newCircle, nil
 }
 
@@ -219,6 +264,10 @@ func (s *CircleMatchingService) AddUserToCircle(ctx context.Context, circleID, u
 		return fmt.Errorf("failed to add user to circle: %w", err)
 	}
 
+	if err := s.e2ee.RecomputeSession(ctx, circleID); err != nil {
+		log.Printf("Failed to recompute E2EE session for circle %s: %v", circleID, err)
+	}
+
 	// Check if circle is now full and update status
 	circle, err := s.client.Circle.FindUnique(
 		db.Circle.ID.Equals(circleID),
@@ -230,6 +279,8 @@ func (s *CircleMatchingService) AddUserToCircle(ctx context.Context, circleID, u
 		return err
 	}
 
+	s.dispatcher.Fire(ctx, webhooks.EventCircleMatched, userID)
+
 	memberships := circle.Memberships()
 	if len(memberships) >= circle.MaxMembers {
 		_, err = s.client.Circle.FindUnique(
@@ -249,6 +300,8 @@ func (s *CircleMatchingService) MatchUserToCircle(ctx context.Context, userID st
 
 	if isCritical {
 		log.Printf("CRITICAL RISK DETECTED for user %s (PHQ-2: %d, GAD-2: %d)", userID, phq2Total, gad2Total)
+		s.dispatcher.Fire(ctx, webhooks.EventCrisisDetected, userID)
+		s.dispatcher.Fire(ctx, webhooks.EventRiskCritical, userID)
 		// Don't match to circle, return for escalation
 		return nil, true, nil
 	}
@@ -273,3 +326,206 @@ func (s *CircleMatchingService) MatchUserToCircle(ctx context.Context, userID st
 
 	return circle, false, nil
 }
+
+// MatchUserToCircleExtended mirrors MatchUserToCircle, but runs after the
+// full PHQ-9/GAD-7 instrument and uses IsCriticalRiskExtended's finer
+// thresholds - including the PHQ-9 self-harm item - instead of the
+// PHQ-2/GAD-2 screen's.
+func (s *CircleMatchingService) MatchUserToCircleExtended(ctx context.Context, userID string, topics []string, phq9Total, gad7Total, selfHarmItem int) (*db.CircleModel, bool, error) {
+	isCritical := s.IsCriticalRiskExtended(phq9Total, gad7Total, selfHarmItem)
+
+	if isCritical {
+		log.Printf("CRITICAL RISK DETECTED (extended screening) for user %s (PHQ-9: %d, GAD-7: %d, self-harm item: %d)", userID, phq9Total, gad7Total, selfHarmItem)
+		s.dispatcher.Fire(ctx, webhooks.EventCrisisDetected, userID)
+		s.dispatcher.Fire(ctx, webhooks.EventRiskCritical, userID)
+		return nil, true, nil
+	}
+
+	category := s.DetermineCircleCategory(topics)
+	log.Printf("Matching user %s to category: %s", userID, category)
+
+	circle, err := s.FindOrCreateCircle(ctx, category)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := s.AddUserToCircle(ctx, circle.ID, userID); err != nil {
+		return nil, false, err
+	}
+
+	log.Printf("Successfully matched user %s to circle %s", userID, circle.Name)
+
+	return circle, false, nil
+}
+
+// GenerateInviteCode lets an existing circle member (or its moderator)
+// create an 8-character code a trusted friend can redeem to join the same
+// circle directly, bypassing topic-based matching.
+func (s *CircleMatchingService) GenerateInviteCode(ctx context.Context, circleID, inviterUserID string) (string, error) {
+	circle, err := s.client.Circle.FindUnique(
+		db.Circle.ID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil {
+		return "", fmt.Errorf("circle not found: %w", err)
+	}
+
+	if circle.ModeratorID != inviterUserID {
+		membership, err := s.client.CircleMembership.FindFirst(
+			db.CircleMembership.CircleID.Equals(circleID),
+			db.CircleMembership.UserID.Equals(inviterUserID),
+		).Exec(ctx)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return "", fmt.Errorf("failed to verify membership: %w", err)
+		}
+		if membership == nil {
+			return "", fmt.Errorf("user is not a member of this circle")
+		}
+	}
+
+	code, err := s.generateUniqueInviteCode(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := db.DateTime(time.Now().UTC().Add(inviteCodeTTL))
+	_, err = s.client.CircleInvite.CreateOne(
+		db.CircleInvite.Circle.Link(db.Circle.ID.Equals(circleID)),
+		db.CircleInvite.Code.Set(code),
+		db.CircleInvite.CreatedBy.Link(db.User.ID.Equals(inviterUserID)),
+		db.CircleInvite.ExpiresAt.Set(expiresAt),
+		db.CircleInvite.MaxUses.Set(1),
+	).Exec(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	log.Printf("User %s generated invite code for circle %s", inviterUserID, circleID)
+
+	return code, nil
+}
+
+// RedeemInviteCode adds userID directly to the circle behind code,
+// skipping topic-based matching. It enforces a hard cap of
+// MaxMembers+2 so an invite spree can't silently blow past what a
+// moderator can reasonably support.
+func (s *CircleMatchingService) RedeemInviteCode(ctx context.Context, code, userID string) (*db.CircleModel, error) {
+	invite, err := s.client.CircleInvite.FindUnique(
+		db.CircleInvite.Code.Equals(strings.ToUpper(strings.TrimSpace(code))),
+	).With(
+		db.CircleInvite.Circle.Fetch().With(
+			db.Circle.Memberships.Fetch(),
+		),
+	).Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invite code")
+	}
+
+	if time.Now().UTC().After(time.Time(invite.ExpiresAt)) {
+		return nil, fmt.Errorf("invite code has expired")
+	}
+	if invite.UsedCount >= invite.MaxUses {
+		return nil, fmt.Errorf("invite code has already been used")
+	}
+
+	existingMembership, _ := s.client.CircleMembership.FindFirst(
+		db.CircleMembership.UserID.Equals(userID),
+	).Exec(ctx)
+	if existingMembership != nil {
+		return nil, fmt.Errorf("user already in a circle")
+	}
+
+	circle := invite.Circle()
+	memberships := circle.Memberships()
+	hardCap := circle.MaxMembers + inviteMaxMemberExcess
+	if len(memberships) >= hardCap {
+		return nil, fmt.Errorf("circle is full")
+	}
+
+	if _, err := s.client.CircleMembership.CreateOne(
+		db.CircleMembership.Circle.Link(db.Circle.ID.Equals(circle.ID)),
+		db.CircleMembership.User.Link(db.User.ID.Equals(userID)),
+	).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to add user to circle: %w", err)
+	}
+
+	if err := s.e2ee.RecomputeSession(ctx, circle.ID); err != nil {
+		log.Printf("Failed to recompute E2EE session for circle %s: %v", circle.ID, err)
+	}
+
+	if _, err := s.client.CircleInvite.FindUnique(
+		db.CircleInvite.Code.Equals(invite.Code),
+	).Update(
+		db.CircleInvite.UsedCount.Increment(1),
+	).Exec(ctx); err != nil {
+		log.Printf("Failed to mark invite code %s used: %v", invite.Code, err)
+	}
+
+	if len(memberships)+1 > circle.MaxMembers {
+		s.notifyModeratorOverCapacity(ctx, circle, len(memberships)+1)
+	}
+
+	s.dispatcher.Fire(ctx, webhooks.EventCircleMatched, userID)
+
+	log.Printf("User %s redeemed invite code to join circle %s", userID, circle.Name)
+
+	return &circle, nil
+}
+
+// notifyModeratorOverCapacity fires the over-capacity webhook event and
+// emails circle's moderator directly, so an invite spree that pushes a
+// circle past its normal capacity is a real moderator escalation rather
+// than a log line nobody reads.
+func (s *CircleMatchingService) notifyModeratorOverCapacity(ctx context.Context, circle db.CircleModel, memberCount int) {
+	s.dispatcher.Fire(ctx, webhooks.EventCircleOverCapacity, circle.ModeratorID)
+
+	go func() {
+		alertCtx := context.Background()
+		moderator, err := s.client.User.FindUnique(db.User.ID.Equals(circle.ModeratorID)).Exec(alertCtx)
+		if err != nil {
+			log.Printf("Failed to load moderator %s for over-capacity alert: %v", circle.ModeratorID, err)
+			return
+		}
+		if err := s.emailService.SendCircleOverCapacityAlert(moderator.FullName, moderator.Email, circle.Name, memberCount, circle.MaxMembers); err != nil {
+			log.Printf("Failed to send over-capacity alert email for circle %s: %v", circle.ID, err)
+		}
+	}()
+}
+
+// generateUniqueInviteCode generates an 8-character code and retries if
+// it collides with an existing one.
+func (s *CircleMatchingService) generateUniqueInviteCode(ctx context.Context) (string, error) {
+	const maxAttempts = 5
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		code, err := randomInviteCode()
+		if err != nil {
+			return "", err
+		}
+
+		existing, err := s.client.CircleInvite.FindUnique(
+			db.CircleInvite.Code.Equals(code),
+		).Exec(ctx)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return "", fmt.Errorf("failed to check invite code uniqueness: %w", err)
+		}
+		if existing == nil {
+			return code, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to generate a unique invite code")
+}
+
+func randomInviteCode() (string, error) {
+	raw := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, inviteCodeLength)
+	for i, b := range raw {
+		code[i] = inviteCodeCharset[int(b)%len(inviteCodeCharset)]
+	}
+
+	return string(code), nil
+}
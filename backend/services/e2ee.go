@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// ErrSessionMismatch is returned by ValidateEnvelope when a message's epoch
+// or senderKeyID doesn't match the circle's current CircleSession - either
+// the sender hasn't rewrapped since the last rekey, or nobody ever
+// distributed a chain key for them at that epoch, so no recipient could
+// derive the message key regardless.
+var ErrSessionMismatch = errors.New("e2ee: message epoch or sender key does not match the circle's current session")
+
+// E2EEService validates the key-bundle and session invariants that keep
+// circle end-to-end encryption honest. It never sees message plaintext -
+// the server holds no chain keys, so there's nothing here to decrypt with.
+type E2EEService struct {
+	client *db.PrismaClient
+}
+
+func NewE2EEService(client *db.PrismaClient) *E2EEService {
+	return &E2EEService{client: client}
+}
+
+// VerifyBundle checks that signingPublicKey attests to identityPublicKey -
+// signature must be a valid Ed25519 signature over identityPublicKey made
+// with the private half of signingPublicKey. This binds a member's
+// long-lived signing identity to the X25519 key they publish for sealing
+// chain keys, so a key bundle can't be swapped for an unrelated one in
+// transit; it doesn't itself prove the member controls signingPublicKey,
+// which is established once at signup.
+func (s *E2EEService) VerifyBundle(identityPublicKey, signingPublicKey, signature []byte) bool {
+	if len(signingPublicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(signingPublicKey, identityPublicKey, signature)
+}
+
+// ValidateEnvelope rejects a message whose epoch or senderKeyID doesn't
+// match circleID's current session. senderKeyID is the sending member's
+// user ID, since this scheme gives each member one active chain key per
+// epoch; a missing distribution for them at that epoch means they haven't
+// published their key for this rewrap yet.
+func (s *E2EEService) ValidateEnvelope(ctx context.Context, circleID string, epoch int, senderKeyID string) error {
+	session, err := s.client.CircleSession.FindUnique(
+		db.CircleSession.CircleID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil {
+		return ErrSessionMismatch
+	}
+	if session.Epoch != epoch {
+		return ErrSessionMismatch
+	}
+
+	dist, err := s.client.SenderKeyDistribution.FindFirst(
+		db.SenderKeyDistribution.CircleID.Equals(circleID),
+		db.SenderKeyDistribution.FromUserID.Equals(senderKeyID),
+		db.SenderKeyDistribution.Epoch.Equals(epoch),
+	).Exec(ctx)
+	if err != nil || dist == nil {
+		return ErrSessionMismatch
+	}
+
+	return nil
+}
+
+// RecomputeSession upserts circleID's CircleSession to the circle's current
+// epoch and a fingerprint of its current membership. Call this whenever
+// either changes - a rekey, or a member joining or leaving - so
+// ValidateEnvelope is always checked against up-to-date state.
+func (s *E2EEService) RecomputeSession(ctx context.Context, circleID string) error {
+	circle, err := s.client.Circle.FindUnique(
+		db.Circle.ID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	memberships, err := s.client.CircleMembership.FindMany(
+		db.CircleMembership.CircleID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	memberIDs := make([]string, len(memberships))
+	for i, m := range memberships {
+		memberIDs[i] = m.UserID
+	}
+	sort.Strings(memberIDs)
+
+	hash := sha256.New()
+	for _, id := range memberIDs {
+		hash.Write([]byte(id))
+		hash.Write([]byte{0})
+	}
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+	_, err = s.client.CircleSession.UpsertOne(
+		db.CircleSession.CircleID.Equals(circleID),
+	).Create(
+		db.CircleSession.Circle.Link(db.Circle.ID.Equals(circleID)),
+		db.CircleSession.Epoch.Set(circle.Epoch),
+		db.CircleSession.MembershipFingerprint.Set(fingerprint),
+	).Update(
+		db.CircleSession.Epoch.Set(circle.Epoch),
+		db.CircleSession.MembershipFingerprint.Set(fingerprint),
+	).Exec(ctx)
+	return err
+}
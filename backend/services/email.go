@@ -3,29 +3,25 @@ package services
 import (
 	"fmt"
 	"log"
-	"net/smtp"
-	"os"
 	"strings"
+
+	"mindbridge/backend/email"
 )
 
 type EmailService struct {
-	from     string
-	password string
-	smtpHost string
-	smtpPort string
+	sender email.Sender
 }
 
 func NewEmailService() *EmailService {
-	return &EmailService{
-		from:     os.Getenv("SMTP_FROM"),
-		password: os.Getenv("SMTP_PASSWORD"),
-		smtpHost: os.Getenv("SMTP_HOST"),
-		smtpPort: os.Getenv("SMTP_PORT"),
-	}
+	return &EmailService{sender: email.NewFromEnv()}
 }
 
-// SendCriticalRiskAlert sends an email alert for users with critical risk scores
-func (s *EmailService) SendCriticalRiskAlert(userName, userEmail string, phq2Total, gad2Total int, topics []string) error {
+// SendCriticalRiskAlert sends an email alert for a user who has triggered
+// critical risk scores. reason describes what triggered the alert (e.g.
+// "Onboarding screening (PHQ-2/GAD-2)" or "Instant help safety classifier"),
+// so the same escalation path can be reused by callers outside onboarding
+// without the email reading like a screening result it isn't.
+func (s *EmailService) SendCriticalRiskAlert(userName, userEmail, reason string, phq2Total, gad2Total int, topics []string) error {
 	// For now, send to hardcoded escalation email
 	escalationEmail := "atzgg132@gmail.com"
 
@@ -35,7 +31,11 @@ func (s *EmailService) SendCriticalRiskAlert(userName, userEmail string, phq2Tot
 CRITICAL RISK ALERT
 ===================
 
-A user has completed onboarding with concerning screening scores that require immediate attention.
+A user has triggered a critical risk alert that requires immediate attention.
+
+Trigger:
+-----------------
+%s
 
 User Information:
 -----------------
@@ -67,7 +67,66 @@ Recommended Actions:
 
 This is an automated alert from the MindBridge platform.
 For questions, contact the platform administrator.
-	`, userName, userEmail, phq2Total, gad2Total, strings.Join(topics, ", "))
+	`, reason, userName, userEmail, phq2Total, gad2Total, strings.Join(topics, ", "))
+
+	return s.sendEmail(escalationEmail, subject, body)
+}
+
+// SendExtendedCriticalRiskAlert mirrors SendCriticalRiskAlert for the
+// full PHQ-9/GAD-7 instrument's escalation path, calling out the PHQ-9
+// self-harm item answer separately since it alone can trigger the alert
+// regardless of the totals.
+func (s *EmailService) SendExtendedCriticalRiskAlert(userName, userEmail string, phq9Total, gad7Total, selfHarmItem int, topics []string) error {
+	escalationEmail := "atzgg132@gmail.com"
+
+	subject := fmt.Sprintf("⚠️ URGENT: High-Risk User Alert (Extended Screening) - %s", userName)
+
+	selfHarmNote := "No self-harm intent reported on PHQ-9 item 9."
+	if selfHarmItem > 0 {
+		selfHarmNote = fmt.Sprintf("PHQ-9 item 9 (thoughts of self-harm) was answered %d/3 - this alone triggers escalation regardless of the totals below.", selfHarmItem)
+	}
+
+	body := fmt.Sprintf(`
+CRITICAL RISK ALERT (EXTENDED SCREENING)
+=========================================
+
+A user has completed the full PHQ-9/GAD-7 screening with concerning scores that require immediate attention.
+
+User Information:
+-----------------
+Name: %s
+Email: %s
+
+Screening Scores:
+-----------------
+PHQ-9 Total: %d/27
+GAD-7 Total: %d/21
+
+Self-Harm Item:
+---------------
+%s
+
+Topics of Concern:
+-----------------
+%s
+
+Risk Assessment:
+-----------------
+This user's scores indicate a need for immediate professional support. The user has been:
+✓ Provided with crisis resources
+✓ Directed to helplines
+✓ Temporarily paused from circle matching
+
+Recommended Actions:
+-------------------
+1. Review the user's responses as soon as possible
+2. Consider reaching out directly if contact permission was granted
+3. Ensure appropriate professional resources are available
+4. Monitor for any follow-up engagement
+
+This is an automated alert from the MindBridge platform.
+For questions, contact the platform administrator.
+	`, userName, userEmail, phq9Total, gad7Total, selfHarmNote, strings.Join(topics, ", "))
 
 	return s.sendEmail(escalationEmail, subject, body)
 }
@@ -106,23 +165,99 @@ The MindBridge Team
 	return s.sendEmail(userEmail, subject, body)
 }
 
-// sendEmail sends an email using SMTP
-func (s *EmailService) sendEmail(to, subject, body string) error {
-	// If SMTP is not configured, just log the email
-	if s.from == "" || s.smtpHost == "" {
-		log.Printf("EMAIL (SMTP not configured):\nTo: %s\nSubject: %s\n%s\n", to, subject, body)
-		return nil
-	}
+// SendCircleOverCapacityAlert notifies a circle's moderator directly when an
+// invite code redemption pushes membership past MaxMembers, since the
+// redemption itself still has to succeed (within the hard cap) and a
+// moderator otherwise has no way to learn they're now over capacity until
+// they happen to check the roster.
+func (s *EmailService) SendCircleOverCapacityAlert(moderatorName, moderatorEmail, circleName string, memberCount, maxMembers int) error {
+	subject := fmt.Sprintf("%s is now over capacity", circleName)
+
+	body := fmt.Sprintf(`
+Hi %s,
+
+An invite code redemption just pushed "%s" over its normal capacity.
+
+Current Members: %d
+Normal Capacity: %d
+
+This is still within the hard cap MindBridge enforces on invite redemptions, but you may want to:
+• Check in with the circle about pacing discussions with more members
+• Stop sharing the invite code further
+• Consider splitting the circle if it keeps growing
+
+Log in to review the circle: http://localhost:5173/dashboard
+
+The MindBridge Team
+	`, moderatorName, circleName, memberCount, maxMembers)
+
+	return s.sendEmail(moderatorEmail, subject, body)
+}
+
+// SendPasswordResetEmail sends a password reset link containing the
+// single-use token. The token is only ever handled in plaintext here and
+// over the wire to the user - AuthHandler stores only its SHA-256 hash.
+func (s *EmailService) SendPasswordResetEmail(userName, userEmail, token string) error {
+	subject := "Reset your MindBridge password"
+
+	body := fmt.Sprintf(`
+Hi %s,
+
+We received a request to reset your MindBridge password. This link expires in 30 minutes and can only be used once:
 
-	auth := smtp.PlainAuth("", s.from, s.password, s.smtpHost)
+http://localhost:5173/reset-password?token=%s
 
-	message := []byte(fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s", to, subject, body))
+If you didn't request this, you can safely ignore this email - your password won't be changed.
 
-	addr := fmt.Sprintf("%s:%s", s.smtpHost, s.smtpPort)
+The MindBridge Team
+	`, userName, token)
+
+	return s.sendEmail(userEmail, subject, body)
+}
+
+// SendEmailVerificationEmail sends a link to verify a user's email address.
+func (s *EmailService) SendEmailVerificationEmail(userName, userEmail, token string) error {
+	subject := "Verify your MindBridge email"
 
-	err := smtp.SendMail(addr, auth, s.from, []string{to}, message)
-	if err != nil {
-		log.Printf("Failed to send email: %v", err)
+	body := fmt.Sprintf(`
+Hi %s,
+
+Please confirm your email address by visiting the link below. This link expires in 30 minutes and can only be used once:
+
+http://localhost:5173/verify-email?token=%s
+
+The MindBridge Team
+	`, userName, token)
+
+	return s.sendEmail(userEmail, subject, body)
+}
+
+// SendModeratorInviteEmail sends a new moderator their temporary login
+// credentials, used by the database seed/reset scripts.
+func (s *EmailService) SendModeratorInviteEmail(fullName, userEmail, temporaryPassword string) error {
+	subject := "You've been added as a MindBridge moderator"
+
+	body := fmt.Sprintf(`
+Hi %s,
+
+An account has been created for you as a MindBridge circle moderator.
+
+Email: %s
+Temporary password: %s
+
+Please sign in and change your password as soon as possible.
+
+The MindBridge Team
+	`, fullName, userEmail, temporaryPassword)
+
+	return s.sendEmail(userEmail, subject, body)
+}
+
+// sendEmail delivers a message through the configured email.Sender,
+// falling back to logging it when no provider is configured.
+func (s *EmailService) sendEmail(to, subject, body string) error {
+	if err := s.sender.Send(to, subject, body); err != nil {
+		log.Printf("EMAIL (delivery failed, logging instead): %v\nTo: %s\nSubject: %s\n%s\n", err, to, subject, body)
 		return err
 	}
 
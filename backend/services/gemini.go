@@ -1,11 +1,14 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -100,8 +103,16 @@ Anchor: "Finals week can feel overwhelming, and it's affecting your sleep - that
 User: "Everyone seems to have it together except me"
 Anchor: "That comparison pain is so real, and social media makes it worse. Remember: you're comparing your behind-the-scenes to everyone else's highlight reel. Try this: name 3 things you did today that took effort, even tiny ones like getting out of bed or eating lunch. You're doing more than you give yourself credit for. What's one small thing that went okay today?"`
 
-func (s *GeminiService) Chat(conversationHistory []GeminiMessage, userMessage string) (string, error) {
-	// Build the request
+// buildRequest assembles the Gemini request body shared by Chat and
+// ChatStream, prepending the system prompt to the first turn of a
+// conversation and otherwise replaying history verbatim.
+func buildRequest(conversationHistory []GeminiMessage, userMessage string) GeminiRequest {
+	return buildRequestWithPrompt(systemPrompt, conversationHistory, userMessage)
+}
+
+// buildRequestWithPrompt is buildRequest with the system prompt broken out,
+// so callers like GenerateCheckIn can swap in a shorter prompt variant.
+func buildRequestWithPrompt(prompt string, conversationHistory []GeminiMessage, userMessage string) GeminiRequest {
 	req := GeminiRequest{
 		Contents: make([]struct {
 			Parts []struct {
@@ -129,7 +140,7 @@ func (s *GeminiService) Chat(conversationHistory []GeminiMessage, userMessage st
 			Parts: []struct {
 				Text string `json:"text"`
 			}{
-				{Text: systemPrompt + "\n\n" + userMessage},
+				{Text: prompt + "\n\n" + userMessage},
 			},
 			Role: "user",
 		})
@@ -171,6 +182,35 @@ func (s *GeminiService) Chat(conversationHistory []GeminiMessage, userMessage st
 		})
 	}
 
+	return req
+}
+
+func (s *GeminiService) Chat(conversationHistory []GeminiMessage, userMessage string) (string, error) {
+	req := buildRequest(conversationHistory, userMessage)
+	return s.generate(req)
+}
+
+// checkInSystemPrompt is a much shorter variant of systemPrompt used for
+// scheduled wellness check-ins, which are a single unprompted message
+// rather than a back-and-forth conversation.
+const checkInSystemPrompt = `You are Anchor, a supportive peer companion for college students, sending a brief, unprompted wellness check-in.
+
+- Keep it to 2-3 warm, low-pressure sentences
+- Don't diagnose or claim to be a professional
+- End by inviting them to reply if they want to talk
+- No medical claims or therapeutic language`
+
+// GenerateCheckIn produces a short, unprompted wellness check-in message
+// for the notifier subsystem to send, using a shorter system prompt than a
+// live chat turn since this isn't a reply to anything the user said.
+func (s *GeminiService) GenerateCheckIn(userFirstName string) (string, error) {
+	req := buildRequestWithPrompt(checkInSystemPrompt, nil, fmt.Sprintf("Write a check-in message for %s.", userFirstName))
+	return s.generate(req)
+}
+
+// generate sends req to the non-streaming generateContent endpoint and
+// returns the first candidate's text.
+func (s *GeminiService) generate(req GeminiRequest) (string, error) {
 	// Marshal request
 	jsonData, err := json.Marshal(req)
 	if err != nil {
@@ -215,3 +255,180 @@ func (s *GeminiService) Chat(conversationHistory []GeminiMessage, userMessage st
 
 	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
 }
+
+// safetyClassificationPrompt asks Gemini to score a single message against
+// the same SafetyCategory taxonomy the regex classifier uses, returning
+// strict JSON instead of the conversational text Chat/ChatStream expect.
+const safetyClassificationPrompt = `You are a safety classifier for a mental health peer support chat. Read the message below and respond with ONLY a JSON object of this exact shape, no other text, no markdown fences:
+
+{"severity": "low|medium|high|critical", "categories": ["self_harm"|"harm_to_others"|"doxxing"|"substance_abuse"|"eating_disorder"|"domestic_abuse"|"sexual_harm", ...], "confidence": 0.0-1.0}
+
+Use "critical" only for an explicit, immediate risk to the user or someone else. If nothing in the taxonomy applies, respond with {"severity": "low", "categories": [], "confidence": 0}.
+
+Message:`
+
+// SafetyVerdict is the structured classification ClassifySafety parses out
+// of Gemini's reply.
+type SafetyVerdict struct {
+	Severity   string   `json:"severity"`
+	Categories []string `json:"categories"`
+	Confidence float64  `json:"confidence"`
+}
+
+// ClassifySafety asks Gemini to classify a single message against the
+// SafetyCategory taxonomy. Unlike Chat/ChatStream this is a one-shot
+// generateContent call with no conversation history - it's the second,
+// slower pass SafetyPipeline runs only after the fast regex classifier has
+// already flagged something, so the extra latency only ever falls on
+// content worth a closer look.
+func (s *GeminiService) ClassifySafety(ctx context.Context, content string) (SafetyVerdict, error) {
+	req := buildRequestWithPrompt(safetyClassificationPrompt, nil, content)
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return SafetyVerdict{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/gemini-2.5-flash:generateContent?key=%s", s.apiKey)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return SafetyVerdict{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return SafetyVerdict{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return SafetyVerdict{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SafetyVerdict{}, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return SafetyVerdict{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
+		return SafetyVerdict{}, fmt.Errorf("no response from Gemini API")
+	}
+
+	text := strings.TrimSpace(geminiResp.Candidates[0].Content.Parts[0].Text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var verdict SafetyVerdict
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &verdict); err != nil {
+		return SafetyVerdict{}, fmt.Errorf("failed to parse safety verdict: %w. Body: %s", err, text)
+	}
+	return verdict, nil
+}
+
+// geminiStreamChunk mirrors the per-event payload emitted by the
+// streamGenerateContent SSE endpoint - same shape as GeminiResponse, just
+// decoded once per "data:" line instead of once for the whole body.
+type geminiStreamChunk struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+// ChatStream calls the Gemini streamGenerateContent endpoint with
+// alt=sse and yields partial text chunks on the returned channel as they
+// arrive, instead of waiting for the full response like Chat does. Both
+// channels are closed when the stream ends; callers should drain the
+// error channel after the text channel closes to learn whether it ended
+// cleanly. Canceling ctx (e.g. on client disconnect) aborts the upstream
+// request and closes the body.
+//
+// Deliberately reuses this shape rather than introducing a separate
+// GeminiDelta/cancel-func pair: InstantHelpHandler.ChatStream already
+// multiplexes this channel against a keepalive ticker and a mid-stream
+// crisis-keyword interrupt via ctx cancellation, and a second streaming
+// API on GeminiService would just be this one wrapped.
+func (s *GeminiService) ChatStream(ctx context.Context, conversationHistory []GeminiMessage, userMessage string) (<-chan string, <-chan error) {
+	chunks := make(chan string)
+	errs := make(chan error, 1)
+
+	req := buildRequest(conversationHistory, userMessage)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+
+		jsonData, err := json.Marshal(req)
+		if err != nil {
+			errs <- fmt.Errorf("failed to marshal request: %w", err)
+			return
+		}
+
+		url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/gemini-2.5-flash:streamGenerateContent?alt=sse&key=%s", s.apiKey)
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+		if err != nil {
+			errs <- fmt.Errorf("failed to create request: %w", err)
+			return
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Accept", "text/event-stream")
+
+		resp, err := s.client.Do(httpReq)
+		if err != nil {
+			errs <- fmt.Errorf("failed to send request: %w", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			errs <- fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+			return
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := scanner.Text()
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+
+			var chunk geminiStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+
+			if len(chunk.Candidates) == 0 || len(chunk.Candidates[0].Content.Parts) == 0 {
+				continue
+			}
+
+			text := chunk.Candidates[0].Content.Parts[0].Text
+			if text == "" {
+				continue
+			}
+
+			select {
+			case chunks <- text:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			errs <- fmt.Errorf("failed to read stream: %w", err)
+		}
+	}()
+
+	return chunks, errs
+}
@@ -1,8 +1,17 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 type SafetyCategory string
@@ -23,6 +32,28 @@ type SafetyMatch struct {
 	MatchedText string
 	Start       int
 	End         int
+	// Confidence is how sure the classifier that produced this match is,
+	// from 0 to 1. The regex classifier is deterministic and always
+	// reports 1.0; a probabilistic backend (e.g. an LLM moderation
+	// endpoint) reports whatever it scored the category at, so
+	// downstream code can tell "regex hit on 'kill it'" apart from
+	// "0.97 self-harm from the LLM".
+	Confidence float64
+	// RedactionType labels the specific PII subtype for mask placeholders
+	// (e.g. "SSN", "EMAIL"); empty falls back to the category name so
+	// every match still masks to something typed.
+	RedactionType string
+}
+
+// Redaction records one span of MaskedContent that was replaced with a
+// typed placeholder like [REDACTED:SSN]. Start/OriginalLen describe the
+// UNMASKED content, not MaskedContent itself (placeholders rarely match
+// the original span's length), so an authorized moderator UI holding the
+// original content can reveal exactly that span back.
+type Redaction struct {
+	Type        string
+	Start       int
+	OriginalLen int
 }
 
 type SafetyResult struct {
@@ -30,12 +61,179 @@ type SafetyResult struct {
 	MaskedContent string
 	Matches       []SafetyMatch
 	RequiresEscalation bool
+	// CategoryScores is the highest Confidence reported for each category
+	// across every classifier in the chain, for callers (onboarding
+	// escalation, circle moderation) that want a single aggregated score
+	// per category rather than walking Matches themselves.
+	CategoryScores map[SafetyCategory]float64
+	// Blocked reports whether any match resolved to EnforcementActionBlock
+	// for the checked scope, outside of Dryrun.
+	Blocked bool
+	// Actions is the deduped union of every enforcement action the
+	// SafetyPolicy resolved across all matches, for the scope CheckContent
+	// was called with. It includes EnforcementActionDryrun when a
+	// resolved rule is still being tuned, so a caller/moderator can see
+	// what would have happened without content actually being masked or
+	// escalated.
+	Actions []EnforcementAction
+	// Redactions is one entry per span MaskedContent replaced with a
+	// typed placeholder, in the same order they appear in MaskedContent.
+	Redactions []Redaction
+}
+
+// EnforcementAction is one thing a SafetyPolicy can do in response to a
+// match, borrowed from the scoped-enforcement model policy engines use:
+// several actions can apply to the same match at once (e.g. Mask and
+// Escalate together).
+type EnforcementAction string
+
+const (
+	EnforcementActionWarn     EnforcementAction = "warn"
+	EnforcementActionMask     EnforcementAction = "mask"
+	EnforcementActionBlock    EnforcementAction = "block"
+	EnforcementActionEscalate EnforcementAction = "escalate"
+	// EnforcementActionDryrun suppresses the real effect of every other
+	// action resolved alongside it (no masking, no Blocked/RequiresEscalation)
+	// while still reporting what those actions would have been, so a
+	// moderator can tune a new rule against real traffic before it enforces.
+	EnforcementActionDryrun EnforcementAction = "dryrun"
+)
+
+// SafetyScope identifies the surface content is being checked on - the
+// same category/severity hit can warrant different enforcement in a live
+// chat message than in an onboarding free-text answer.
+type SafetyScope string
+
+const (
+	SafetyScopeChatMessage          SafetyScope = "chat_message"
+	SafetyScopeOnboardingOtherTopic SafetyScope = "onboarding_other_topic"
+	SafetyScopeJournalEntry         SafetyScope = "journal_entry"
+	SafetyScopeCirclePost           SafetyScope = "circle_post"
+)
+
+type policyKey struct {
+	Scope    SafetyScope
+	Category SafetyCategory
+	Severity string
+}
+
+// SafetyPolicy maps a (scope, category, severity) tuple to the
+// enforcement actions content matching it should trigger. A tuple with
+// no explicit rule falls back to defaultActionsForSeverity, so ops only
+// need to add rules for the cases where a scope should deviate from it.
+type SafetyPolicy struct {
+	mu    sync.RWMutex
+	rules map[policyKey][]EnforcementAction
+}
+
+// NewSafetyPolicy returns an empty policy - every tuple resolves to
+// defaultActionsForSeverity until SetActions overrides it.
+func NewSafetyPolicy() *SafetyPolicy {
+	return &SafetyPolicy{rules: map[policyKey][]EnforcementAction{}}
+}
+
+// SetActions maps (scope, category, severity) to actions, e.g. switching
+// a newly added pattern to EnforcementActionDryrun while a moderator
+// reviews it against real traffic before it starts masking or escalating.
+func (p *SafetyPolicy) SetActions(scope SafetyScope, category SafetyCategory, severity string, actions ...EnforcementAction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules[policyKey{Scope: scope, Category: category, Severity: severity}] = actions
+}
+
+func (p *SafetyPolicy) actionsFor(scope SafetyScope, category SafetyCategory, severity string) []EnforcementAction {
+	p.mu.RLock()
+	actions, ok := p.rules[policyKey{Scope: scope, Category: category, Severity: severity}]
+	p.mu.RUnlock()
+	if ok {
+		return actions
+	}
+	return defaultActionsForSeverity(severity)
+}
+
+// defaultActionsForSeverity is what every (scope, category, severity)
+// tuple resolves to until a SafetyPolicy rule overrides it.
+func defaultActionsForSeverity(severity string) []EnforcementAction {
+	switch severity {
+	case "critical":
+		return []EnforcementAction{EnforcementActionMask, EnforcementActionEscalate}
+	case "high":
+		return []EnforcementAction{EnforcementActionMask, EnforcementActionWarn}
+	default:
+		return []EnforcementAction{EnforcementActionWarn}
+	}
+}
+
+func dedupeActions(actions []EnforcementAction) []EnforcementAction {
+	seen := make(map[EnforcementAction]bool, len(actions))
+	deduped := make([]EnforcementAction, 0, len(actions))
+	for _, action := range actions {
+		if seen[action] {
+			continue
+		}
+		seen[action] = true
+		deduped = append(deduped, action)
+	}
+	return deduped
+}
+
+// SafetyClassifier is one backend in the chain SafetyService runs content
+// through. Implementations range from the deterministic regexClassifier
+// shipped in this package to a remote ML/LLM moderation endpoint - each
+// just needs to turn content into zero or more SafetyMatch findings.
+type SafetyClassifier interface {
+	// Name identifies this classifier in the SAFETY_CLASSIFIERS config list.
+	Name() string
+	Classify(ctx context.Context, content string) ([]SafetyMatch, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]SafetyClassifier{}
+)
+
+// Register adds (or replaces) a named SafetyClassifier backend in the
+// process-wide registry, so it can be named in SAFETY_CLASSIFIERS without
+// SafetyService needing to import every backend directly. Backends in
+// this package register themselves from init().
+func Register(name string, c SafetyClassifier) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = c
+}
+
+func lookupClassifier(name string) (SafetyClassifier, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+func init() {
+	Register("regex", regexClassifier{})
+	Register("llm", newLLMClassifierFromEnv())
 }
 
 type SafetyPattern struct {
 	Pattern  *regexp.Regexp
 	Category SafetyCategory
 	Severity string
+	// RedactionType labels this pattern's specific PII subtype for mask
+	// placeholders (e.g. "SSN"); left empty for patterns where the
+	// category itself is the best available label (e.g. self-harm phrases).
+	RedactionType string
+	// RequiresLuhn additionally validates a match's digits against the
+	// Luhn checksum before it's accepted, so a plain 16-digit order or
+	// tracking number isn't falsely flagged as a credit card.
+	RequiresLuhn bool
+	// RawContentOnly marks a pattern whose shape is digit- or "@"-anchored
+	// (SSN, credit card, email, IPv4/IPv6, IBAN) and would be destroyed by
+	// normalize()'s leet/homoglyph folding (e.g. "078-05-1120" becomes
+	// "ot8-os-ii2o", "foo@bar.com" becomes "fooabar.com"). CheckContent
+	// scans these against the message's original bytes instead of the
+	// normalized string; every other pattern keeps scanning normalized
+	// content so spaced-out or leetspoken phrases still get caught.
+	RawContentOnly bool
 }
 
 var safetyPatterns = []SafetyPattern{
@@ -99,24 +297,59 @@ var safetyPatterns = []SafetyPattern{
 
 	// Doxxing - Critical
 	{
-		Pattern:  regexp.MustCompile(`(?i)\b(my\s+address\s+is|live\s+at\s+\d+|password\s+is|social\s+security|ssn\s+is)\b`),
-		Category: SafetyCategoryDoxxing,
-		Severity: "critical",
+		Pattern:       regexp.MustCompile(`(?i)\b(my\s+address\s+is|live\s+at\s+\d+|password\s+is|social\s+security|ssn\s+is)\b`),
+		Category:      SafetyCategoryDoxxing,
+		Severity:      "critical",
+		RedactionType: "ADDRESS",
 	},
 	{
-		Pattern:  regexp.MustCompile(`(?i)\b(phone\s+number\s+is\s+\d|call\s+me\s+at\s+\d|credit\s+card|bank\s+account\s+number)\b`),
-		Category: SafetyCategoryDoxxing,
-		Severity: "critical",
+		Pattern:       regexp.MustCompile(`(?i)\b(phone\s+number\s+is\s+\d|call\s+me\s+at\s+\d|credit\s+card|bank\s+account\s+number)\b`),
+		Category:      SafetyCategoryDoxxing,
+		Severity:      "critical",
+		RedactionType: "PHONE",
 	},
 	{
-		Pattern:  regexp.MustCompile(`\b\d{3}[-.\s]?\d{2}[-.\s]?\d{4}\b`), // SSN pattern
-		Category: SafetyCategoryDoxxing,
-		Severity: "critical",
+		Pattern:        regexp.MustCompile(`\b\d{3}[-.\s]?\d{2}[-.\s]?\d{4}\b`), // SSN pattern
+		Category:       SafetyCategoryDoxxing,
+		Severity:       "critical",
+		RedactionType:  "SSN",
+		RawContentOnly: true,
 	},
 	{
-		Pattern:  regexp.MustCompile(`\b\d{4}[-.\s]?\d{4}[-.\s]?\d{4}[-.\s]?\d{4}\b`), // Credit card pattern
-		Category: SafetyCategoryDoxxing,
-		Severity: "critical",
+		Pattern:        regexp.MustCompile(`\b\d{4}[-.\s]?\d{4}[-.\s]?\d{4}[-.\s]?\d{4}\b`), // Credit card pattern
+		Category:       SafetyCategoryDoxxing,
+		Severity:       "critical",
+		RedactionType:  "CREDIT_CARD",
+		RequiresLuhn:   true,
+		RawContentOnly: true,
+	},
+	{
+		Pattern:        regexp.MustCompile(`(?i)\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+		Category:       SafetyCategoryDoxxing,
+		Severity:       "medium",
+		RedactionType:  "EMAIL",
+		RawContentOnly: true,
+	},
+	{
+		Pattern:        regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+		Category:       SafetyCategoryDoxxing,
+		Severity:       "medium",
+		RedactionType:  "IPV4",
+		RawContentOnly: true,
+	},
+	{
+		Pattern:        regexp.MustCompile(`\b(?:[A-Fa-f0-9]{1,4}:){7}[A-Fa-f0-9]{1,4}\b`),
+		Category:       SafetyCategoryDoxxing,
+		Severity:       "medium",
+		RedactionType:  "IPV6",
+		RawContentOnly: true,
+	},
+	{
+		Pattern:        regexp.MustCompile(`\b[A-Z]{2}\d{2}[A-Z0-9]{11,30}\b`),
+		Category:       SafetyCategoryDoxxing,
+		Severity:       "critical",
+		RedactionType:  "IBAN",
+		RawContentOnly: true,
 	},
 
 	// Substance Abuse - Critical
@@ -187,76 +420,327 @@ var safetyPatterns = []SafetyPattern{
 	},
 }
 
-type SafetyService struct{}
+// regexClassifier is the original, always-available SafetyClassifier:
+// a deterministic sweep over safetyPatterns, registered under "regex".
+// The sweep itself runs through an Aho-Corasick pre-filter and a single
+// combined regex pass rather than looping safetyPatterns one at a time -
+// see safety_scanner.go - but the output is identical either way.
+type regexClassifier struct{}
+
+func (regexClassifier) Name() string { return "regex" }
+
+func (regexClassifier) Classify(ctx context.Context, content string) ([]SafetyMatch, error) {
+	return scanForSafetyMatches(content), nil
+}
+
+// rawContentClassifier is implemented by a classifier that also needs to
+// see a message's original, unnormalized bytes - currently just
+// regexClassifier, for the RawContentOnly patterns normalize()'s
+// leet/homoglyph folding would otherwise corrupt. CheckContent gives
+// every classifier the normalized string via Classify as usual, then
+// gives this extra pass the raw content too.
+type rawContentClassifier interface {
+	ClassifyRaw(ctx context.Context, content string) ([]SafetyMatch, error)
+}
+
+func (regexClassifier) ClassifyRaw(ctx context.Context, content string) ([]SafetyMatch, error) {
+	return scanForRawSafetyMatches(content), nil
+}
+
+// llmConfidenceThreshold is the minimum per-category probability the llm
+// classifier requires before it reports a match at all.
+const llmConfidenceThreshold = 0.5
+
+// llmClassifier calls out to an external moderation model that scores
+// content against the same SafetyCategory taxonomy the regex classifier
+// uses, returning a probability per category instead of a fixed pattern
+// match. Registered under "llm"; a no-op (returns an error, logged and
+// skipped by CheckContent) until SAFETY_LLM_ENDPOINT is configured.
+type llmClassifier struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func newLLMClassifierFromEnv() *llmClassifier {
+	return &llmClassifier{
+		endpoint: os.Getenv("SAFETY_LLM_ENDPOINT"),
+		apiKey:   os.Getenv("SAFETY_LLM_API_KEY"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *llmClassifier) Name() string { return "llm" }
+
+type llmModerationRequest struct {
+	Content string `json:"content"`
+}
+
+type llmModerationResponse struct {
+	Categories map[SafetyCategory]float64 `json:"categories"`
+}
+
+func (c *llmClassifier) Classify(ctx context.Context, content string) ([]SafetyMatch, error) {
+	if c.endpoint == "" {
+		return nil, fmt.Errorf("services: SAFETY_LLM_ENDPOINT not configured")
+	}
+
+	body, err := json.Marshal(llmModerationRequest{Content: content})
+	if err != nil {
+		return nil, fmt.Errorf("services: failed to encode moderation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("services: failed to build moderation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("services: moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("services: moderation endpoint returned status %d", resp.StatusCode)
+	}
+
+	var parsed llmModerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("services: failed to decode moderation response: %w", err)
+	}
+
+	var matches []SafetyMatch
+	for category, confidence := range parsed.Categories {
+		if confidence < llmConfidenceThreshold {
+			continue
+		}
+		matches = append(matches, SafetyMatch{
+			Category:   category,
+			Severity:   severityForConfidence(confidence),
+			Confidence: confidence,
+		})
+	}
+	return matches, nil
+}
+
+func severityForConfidence(confidence float64) string {
+	switch {
+	case confidence >= 0.85:
+		return "critical"
+	case confidence >= 0.6:
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+type SafetyService struct {
+	classifiers []SafetyClassifier
+	policy      *SafetyPolicy
+}
 
+// NewSafetyService builds a SafetyService running just the built-in regex
+// classifier under the default severity-based policy, for callers that
+// don't need a configurable chain or scoped enforcement rules.
 func NewSafetyService() *SafetyService {
-	return &SafetyService{}
+	return &SafetyService{classifiers: []SafetyClassifier{regexClassifier{}}, policy: NewSafetyPolicy()}
+}
+
+// Policy returns the service's SafetyPolicy, so callers (e.g. an admin
+// handler) can tune scoped enforcement rules - such as putting a new
+// pattern in EnforcementActionDryrun - without rebuilding the service.
+func (s *SafetyService) Policy() *SafetyPolicy {
+	return s.policy
+}
+
+// NewSafetyServiceFromEnv builds the classifier chain named in the
+// comma-separated SAFETY_CLASSIFIERS env var (e.g. "regex,llm"), falling
+// back to "regex" alone if unset, so ops can enable or disable backends
+// without recompiling. An unrecognized name is logged and skipped rather
+// than failing startup.
+func NewSafetyServiceFromEnv() *SafetyService {
+	names := os.Getenv("SAFETY_CLASSIFIERS")
+	if names == "" {
+		names = "regex"
+	}
+
+	var classifiers []SafetyClassifier
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c, ok := lookupClassifier(name)
+		if !ok {
+			log.Printf("services: unknown safety classifier %q, skipping", name)
+			continue
+		}
+		classifiers = append(classifiers, c)
+	}
+
+	if len(classifiers) == 0 {
+		classifiers = []SafetyClassifier{regexClassifier{}}
+	}
+
+	return &SafetyService{classifiers: classifiers, policy: NewSafetyPolicy()}
 }
 
 type matchPos struct {
-	start    int
-	end      int
-	category SafetyCategory
-	severity string
+	start         int
+	end           int
+	category      SafetyCategory
+	severity      string
+	redactionType string
+}
+
+// redactionLabel is what a match's placeholder reads as: its pattern's
+// own RedactionType (e.g. "SSN") if it has one, otherwise the category
+// name, so every masked match still masks to something typed.
+func redactionLabel(category SafetyCategory, redactionType string) string {
+	if redactionType != "" {
+		return redactionType
+	}
+	return strings.ToUpper(string(category))
 }
 
-// CheckContent analyzes content for safety concerns and returns masked version
-func (s *SafetyService) CheckContent(content string) SafetyResult {
+// CheckContent runs content through every classifier in the chain, then
+// resolves each match against the SafetyPolicy for scope to decide what
+// should actually happen: which matches get masked, whether the result
+// is Blocked or RequiresEscalation, and the full resolved Actions set. A
+// match whose resolved actions include EnforcementActionDryrun still
+// reports what it would have done, but its Mask/Block/Escalate effects
+// are suppressed so moderators can tune a rule against real traffic
+// before it enforces.
+func (s *SafetyService) CheckContent(ctx context.Context, scope SafetyScope, content string) SafetyResult {
 	result := SafetyResult{
-		Flagged:       false,
-		MaskedContent: content,
-		Matches:       []SafetyMatch{},
-		RequiresEscalation: false,
+		MaskedContent:  content,
+		Matches:        []SafetyMatch{},
+		CategoryScores: map[SafetyCategory]float64{},
 	}
 
 	if strings.TrimSpace(content) == "" {
 		return result
 	}
 
+	// normalize undoes zero-width insertion, homoglyph/leet substitution,
+	// and spaced-out letters before anything tries to pattern-match, so
+	// classifiers see "kill myself" instead of "k1ll  m y s e l f". Their
+	// matches come back in normalized coordinates; offsetMap translates
+	// them to content's actual bytes below.
+	normalized, offsetMap := normalize(content)
+
 	// Track all match positions for masking
-	var matches []matchPos
+	var spans []matchPos
+	var actions []EnforcementAction
+
+	// record resolves policy actions for one match and folds its effects
+	// into result/spans/actions. Shared by both the normalized-content
+	// pass every classifier gets and the raw-content pass rawContentClassifier
+	// implementations get, so the enforcement logic isn't duplicated.
+	record := func(match SafetyMatch) {
+		result.Flagged = true
+
+		if match.Confidence > result.CategoryScores[match.Category] {
+			result.CategoryScores[match.Category] = match.Confidence
+		}
 
-	// Check all patterns
-	for _, pattern := range safetyPatterns {
-		allMatches := pattern.Pattern.FindAllStringIndex(content, -1)
-		for _, match := range allMatches {
-			result.Flagged = true
+		result.Matches = append(result.Matches, match)
 
-			// Check if this is critical severity - requires escalation
-			if pattern.Severity == "critical" {
+		resolved := s.policy.actionsFor(scope, match.Category, match.Severity)
+		actions = append(actions, resolved...)
+
+		dryrun := false
+		for _, action := range resolved {
+			if action == EnforcementActionDryrun {
+				dryrun = true
+				break
+			}
+		}
+		if dryrun {
+			return
+		}
+
+		for _, action := range resolved {
+			switch action {
+			case EnforcementActionEscalate:
 				result.RequiresEscalation = true
+			case EnforcementActionBlock:
+				result.Blocked = true
+			case EnforcementActionMask:
+				// A whole-content classifier (e.g. the LLM backend)
+				// reports a confidence without a literal substring to
+				// black out; only span-backed matches (Start < End)
+				// contribute to masking.
+				if match.End > match.Start {
+					spans = append(spans, matchPos{
+						start:         match.Start,
+						end:           match.End,
+						category:      match.Category,
+						severity:      match.Severity,
+						redactionType: redactionLabel(match.Category, match.RedactionType),
+					})
+				}
 			}
+		}
+	}
 
-			matchText := content[match[0]:match[1]]
-			result.Matches = append(result.Matches, SafetyMatch{
-				Category:    pattern.Category,
-				Severity:    pattern.Severity,
-				MatchedText: matchText,
-				Start:       match[0],
-				End:         match[1],
-			})
-
-			matches = append(matches, matchPos{
-				start:    match[0],
-				end:      match[1],
-				category: pattern.Category,
-				severity: pattern.Severity,
-			})
+	for _, classifier := range s.classifiers {
+		classified, err := classifier.Classify(ctx, normalized)
+		if err != nil {
+			log.Printf("services: safety classifier %q failed: %v", classifier.Name(), err)
+			continue
+		}
+
+		for _, match := range classified {
+			// Only span-backed matches (Start < End) need translating -
+			// a whole-content classifier (e.g. the LLM backend) reports a
+			// confidence with no literal substring, so its zero-value
+			// Start/End pass through untouched.
+			if match.End > match.Start && match.Start < len(offsetMap) && match.End < len(offsetMap) {
+				match.Start, match.End = offsetMap[match.Start], offsetMap[match.End]
+				match.MatchedText = content[match.Start:match.End]
+			}
+			record(match)
+		}
+
+		rc, ok := classifier.(rawContentClassifier)
+		if !ok {
+			continue
+		}
+		rawMatches, err := rc.ClassifyRaw(ctx, content)
+		if err != nil {
+			log.Printf("services: safety classifier %q failed on raw content: %v", classifier.Name(), err)
+			continue
+		}
+		// rawMatches are already content-relative - ClassifyRaw scanned
+		// content itself, never normalized, so there's no offsetMap to
+		// translate through.
+		for _, match := range rawMatches {
+			record(match)
 		}
 	}
 
+	result.Actions = dedupeActions(actions)
+
 	// Mask content if flagged
-	if result.Flagged {
-		result.MaskedContent = s.maskContent(content, matches)
+	if len(spans) > 0 {
+		result.MaskedContent, result.Redactions = s.maskContent(content, spans)
 	}
 
 	return result
 }
 
-// maskContent replaces sensitive content with masking characters
-func (s *SafetyService) maskContent(content string, matches []matchPos) string {
+// maskContent replaces each matched span with a typed placeholder like
+// [REDACTED:SSN] and returns a parallel Redaction per span (in content
+// order) so a moderator UI with the original content can reveal one back.
+func (s *SafetyService) maskContent(content string, matches []matchPos) (string, []Redaction) {
 	if len(matches) == 0 {
-		return content
+		return content, nil
 	}
 
 	// Sort matches by start position (descending) to replace from end to start
@@ -292,15 +776,22 @@ func (s *SafetyService) maskContent(content string, matches []matchPos) string {
 		}
 	}
 
-	// Apply masking from end to start
+	// Apply masking from end to start, so earlier offsets in `merged`
+	// (sorted descending) stay valid as later ones are replaced.
 	runes := []rune(content)
+	var redactions []Redaction
 	for _, match := range merged {
-		maskLength := match.end - match.start
-		mask := strings.Repeat("▮", maskLength)
-		runes = append(runes[:match.start], append([]rune(mask), runes[match.end:]...)...)
+		redactions = append([]Redaction{{
+			Type:        match.redactionType,
+			Start:       match.start,
+			OriginalLen: match.end - match.start,
+		}}, redactions...)
+
+		placeholder := []rune(fmt.Sprintf("[REDACTED:%s]", match.redactionType))
+		runes = append(runes[:match.start], append(placeholder, runes[match.end:]...)...)
 	}
 
-	return string(runes)
+	return string(runes), redactions
 }
 
 // GetCategoryDescription returns a human-readable description of the safety category
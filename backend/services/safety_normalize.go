@@ -0,0 +1,146 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalize is CheckContent's first step, undoing the common ways users
+// evade safetyPatterns without actually changing what they're saying:
+// zero-width/control characters inserted between letters, homoglyphs and
+// mathematical-alphanumeric lookalikes standing in for ASCII letters,
+// leetspeak digit substitution, and spacing every letter out. Pattern
+// matching then runs against the returned string, not content itself.
+//
+// offsetMap has len(normalized)+1 entries: offsetMap[i] is the byte
+// offset in content that normalized byte i came from, and the final
+// entry is len(content) - a sentinel so a match's end index can be
+// translated the same way as its start. Folding and leet substitution
+// keep a strict one rune in, one rune out correspondence so this mapping
+// stays simple; the only thing that changes rune count is collapsing
+// spaced-out letters, which only ever deletes separator spaces.
+func normalize(content string) (string, []int) {
+	type foldedRune struct {
+		r      rune
+		origAt int
+	}
+
+	folded := make([]foldedRune, 0, len(content))
+	for i, r := range content {
+		if isIgnoredRune(r) {
+			continue
+		}
+		folded = append(folded, foldedRune{r: foldRune(r), origAt: i})
+	}
+
+	n := len(folded)
+	isLetterAt := func(i int) bool { return i >= 0 && i < n && unicode.IsLetter(folded[i].r) }
+	isSingleLetterToken := func(i int) bool { return isLetterAt(i) && !isLetterAt(i-1) && !isLetterAt(i+1) }
+
+	collapsed := make([]foldedRune, 0, n)
+	for i := 0; i < n; {
+		if isSingleLetterToken(i) {
+			j := i
+			count := 1
+			for j+2 < n && folded[j+1].r == ' ' && isSingleLetterToken(j+2) {
+				count++
+				j += 2
+			}
+			if count >= 3 {
+				for k := i; k <= j; k += 2 {
+					collapsed = append(collapsed, folded[k])
+				}
+				i = j + 1
+				continue
+			}
+		}
+		collapsed = append(collapsed, folded[i])
+		i++
+	}
+
+	var b strings.Builder
+	offsetMap := make([]int, 0, len(content)+1)
+	for _, f := range collapsed {
+		start := b.Len()
+		b.WriteRune(f.r)
+		for k := 0; k < b.Len()-start; k++ {
+			offsetMap = append(offsetMap, f.origAt)
+		}
+	}
+	offsetMap = append(offsetMap, len(content))
+
+	return b.String(), offsetMap
+}
+
+// isIgnoredRune reports whether r is a zero-width or control character
+// commonly stitched between letters to break up a flagged word, and
+// should just be dropped rather than folded.
+func isIgnoredRune(r rune) bool {
+	switch r {
+	case '​', '‌', '‍', '﻿': // ZWSP, ZWNJ, ZWJ, BOM
+		return true
+	}
+	return r < 0x20 && r != '\t' && r != '\n' && r != '\r'
+}
+
+// leetFoldMap is the digit/symbol side of leetspeak substitution.
+var leetFoldMap = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+}
+
+// homoglyphFoldMap covers the Cyrillic and Greek letters most commonly
+// substituted for visually identical Latin ones; NFKC doesn't fold these
+// since they're distinct letters in their own scripts, not compatibility
+// variants of a Latin one.
+var homoglyphFoldMap = map[rune]rune{
+	'а': 'a', // Cyrillic a
+	'е': 'e', // Cyrillic ie
+	'і': 'i', // Cyrillic i
+	'о': 'o', // Cyrillic o
+	'р': 'p', // Cyrillic er
+	'с': 'c', // Cyrillic es
+	'х': 'x', // Cyrillic ha
+	'у': 'y', // Cyrillic u
+	'ѕ': 's', // Cyrillic dze
+	'α': 'a', // Greek alpha
+	'ο': 'o', // Greek omicron
+	'ρ': 'p', // Greek rho
+}
+
+// foldRune lowercases r, then folds it to ASCII via (in order) leetspeak
+// substitution, the homoglyph table, or a per-rune NFKC decomposition.
+// NFKC runs rune-by-rune rather than over the whole string specifically
+// so the result stays one rune in, one rune out - this is what catches
+// mathematical-alphanumeric lookalikes like the bold-script "suicide" in
+// the title of this change, since those decompose to plain Latin letters
+// under compatibility normalization. A decomposition that expands to more
+// than one rune (e.g. some ligatures) is skipped rather than applied, to
+// preserve that invariant; it's a rare case to miss.
+func foldRune(r rune) rune {
+	lower := unicode.ToLower(r)
+
+	if folded, ok := leetFoldMap[lower]; ok {
+		return folded
+	}
+	if folded, ok := homoglyphFoldMap[lower]; ok {
+		return folded
+	}
+	if lower < unicode.MaxASCII {
+		return lower
+	}
+
+	decomposed := []rune(norm.NFKC.String(string(lower)))
+	if len(decomposed) == 1 && decomposed[0] != lower {
+		return decomposed[0]
+	}
+	return lower
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// severityRank orders severity strings so Escalate can compare the regex
+// pass's resolved severity against Gemini's second-pass verdict without a
+// chain of string comparisons.
+var severityRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// PipelineResult is what SafetyPipeline.Check returns: the regex/keyword
+// pass's SafetyResult, plus Gemini's second-pass verdict when the fast pass
+// flagged something worth the extra model call.
+type PipelineResult struct {
+	SafetyResult
+	GeminiVerdict *SafetyVerdict
+}
+
+// Escalate reports whether either pass puts this content at high or
+// critical severity - the regex pass's own policy-resolved
+// RequiresEscalation, or Gemini's second-pass verdict independently
+// reaching the same bar.
+func (r PipelineResult) Escalate() bool {
+	if r.RequiresEscalation {
+		return true
+	}
+	return r.GeminiVerdict != nil && severityRank[r.GeminiVerdict.Severity] >= severityRank["high"]
+}
+
+// softLockWindow and softLockThreshold gate SafetyPipeline's circuit
+// breaker: this many critical events from the same user inside the window
+// trip a soft-lock, clearing on its own as events age out of the window.
+const (
+	softLockWindow    = 15 * time.Minute
+	softLockThreshold = 3
+)
+
+// SafetyPipeline wraps GeminiService.Chat's inbound and outbound content
+// with the SafetyService's fast keyword/regex pass, followed by an
+// optional Gemini second-pass classification when the fast pass flags
+// something. It also tracks a per-user rolling count of critical events so
+// repeated escalations within a short window trip a soft-lock instead of
+// letting instant help keep responding to someone it just flagged.
+type SafetyPipeline struct {
+	safety *SafetyService
+	gemini *GeminiService
+
+	mu       sync.Mutex
+	critical map[string][]time.Time
+}
+
+// NewSafetyPipeline returns a pipeline backed by the env-configured
+// SafetyService classifier chain and gemini for the second pass.
+func NewSafetyPipeline(gemini *GeminiService) *SafetyPipeline {
+	return &SafetyPipeline{
+		safety:   NewSafetyServiceFromEnv(),
+		gemini:   gemini,
+		critical: map[string][]time.Time{},
+	}
+}
+
+// Check runs content through the fast regex/keyword classifier, then
+// escalates to a Gemini second pass only if that first pass flagged it.
+// Gemini failures are logged and otherwise swallowed - the regex pass's
+// own result still stands on its own.
+func (p *SafetyPipeline) Check(ctx context.Context, scope SafetyScope, content string) PipelineResult {
+	result := PipelineResult{SafetyResult: p.safety.CheckContent(ctx, scope, content)}
+	if !result.Flagged {
+		return result
+	}
+
+	verdict, err := p.gemini.ClassifySafety(ctx, content)
+	if err != nil {
+		log.Printf("safety: gemini second-pass classification failed: %v", err)
+		return result
+	}
+	result.GeminiVerdict = &verdict
+	return result
+}
+
+// RecordCritical notes a critical-severity event for userID and reports
+// whether it just pushed that user over softLockThreshold within
+// softLockWindow - the circuit breaker tripping.
+func (p *SafetyPipeline) RecordCritical(userID string) bool {
+	now := time.Now()
+	cutoff := now.Add(-softLockWindow)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kept := p.critical[userID][:0]
+	for _, t := range p.critical[userID] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, now)
+	p.critical[userID] = kept
+
+	return len(kept) >= softLockThreshold
+}
+
+// IsSoftLocked reports whether userID is currently within a tripped
+// circuit breaker window.
+func (p *SafetyPipeline) IsSoftLocked(userID string) bool {
+	cutoff := time.Now().Add(-softLockWindow)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, t := range p.critical[userID] {
+		if t.After(cutoff) {
+			count++
+		}
+	}
+	return count >= softLockThreshold
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file replaces the naive "loop over every compiled regex and call
+// FindAllStringIndex" scan regexClassifier used to run, which is
+// O(len(safetyPatterns) x len(content)) per message. Two optimizations
+// replace it, in the order content actually flows through them:
+//
+//  1. An Aho-Corasick automaton built once at package init from a literal
+//     keyword seed extracted from every pattern's regex source. One
+//     linear pass over the (lowercased) content tells us whether *any*
+//     pattern has a chance of matching at all - the common case for real
+//     traffic is zero hits, and that pass costs O(len(content)) regardless
+//     of how many patterns exist.
+//  2. A combined regexp, every pattern in the group unioned into one
+//     compiled RE2 alternation with a named capture group per pattern
+//     (p0, p1, ...). It only runs when step 1 found a keyword hit (or the
+//     content has a digit/"@"/":" for a pattern with no useful literal
+//     keyword - see keywordsForPattern). Matching against one compiled
+//     automaton is what actually collapses the per-pattern linear scans
+//     into a single one; winningSafetyPattern then maps the winning group
+//     straight back to (category, severity).
+//
+// safetyPatterns is split into two such groups - see
+// scanForSafetyMatches and scanForRawSafetyMatches - because patterns
+// anchored on digits or "@" don't survive normalize()'s leet/homoglyph
+// folding and need to scan the message's raw bytes instead.
+//
+// See safety_scanner_test.go for a benchmark of this against the old
+// per-pattern loop on a 10k message corpus.
+
+// keywordExtractPattern pulls the literal alphabetic runs out of a
+// pattern's regex source to use as Aho-Corasick seeds. It's deliberately
+// crude: safetyPatterns are all `\b(word|word\s+word|...)\b`-shaped
+// alternations, so every branch that can match has at least one 4+
+// letter literal run in it, and a false-positive keyword (matching more
+// often than the full branch would) only costs an extra combined-regex
+// pass, never a missed detection.
+var keywordExtractPattern = regexp.MustCompile(`[A-Za-z]{4,}`)
+
+func keywordsForPattern(pattern *regexp.Regexp) []string {
+	seen := map[string]bool{}
+	var keywords []string
+	for _, word := range keywordExtractPattern.FindAllString(strings.ToLower(pattern.String()), -1) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+// acNode is one node of the Aho-Corasick trie.
+type acNode struct {
+	children map[byte]*acNode
+	fail     *acNode
+	patterns map[int]bool
+}
+
+func newACNode() *acNode {
+	return &acNode{children: map[byte]*acNode{}, patterns: map[int]bool{}}
+}
+
+// acTrie is a compiled Aho-Corasick automaton over every safetyPatterns
+// entry's extracted keywords, used purely as a fast pre-filter: it tells
+// us which pattern indices have a literal keyword present in the input,
+// never the exact span (that's combinedSafetyRegexp's job).
+type acTrie struct {
+	root *acNode
+}
+
+func buildSafetyKeywordTrie(patterns []SafetyPattern) *acTrie {
+	root := newACNode()
+	for i, pattern := range patterns {
+		for _, keyword := range keywordsForPattern(pattern.Pattern) {
+			node := root
+			for j := 0; j < len(keyword); j++ {
+				c := keyword[j]
+				child, ok := node.children[c]
+				if !ok {
+					child = newACNode()
+					node.children[c] = child
+				}
+				node = child
+			}
+			node.patterns[i] = true
+		}
+	}
+
+	var queue []*acNode
+	for _, child := range root.children {
+		child.fail = root
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for c, child := range node.children {
+			queue = append(queue, child)
+
+			failNode := node.fail
+			for failNode != nil {
+				if next, ok := failNode.children[c]; ok {
+					child.fail = next
+					break
+				}
+				failNode = failNode.fail
+			}
+			if child.fail == nil {
+				child.fail = root
+			}
+			for p := range child.fail.patterns {
+				child.patterns[p] = true
+			}
+		}
+	}
+
+	return &acTrie{root: root}
+}
+
+// hasAnyKeyword runs one linear scan over lower (already-lowercased
+// content) and reports whether at least one pattern's keyword occurs
+// anywhere in it.
+func (t *acTrie) hasAnyKeyword(lower string) bool {
+	node := t.root
+	for i := 0; i < len(lower); i++ {
+		c := lower[i]
+		for node != t.root {
+			if _, ok := node.children[c]; ok {
+				break
+			}
+			node = node.fail
+		}
+		if next, ok := node.children[c]; ok {
+			node = next
+		} else {
+			node = t.root
+		}
+		if len(node.patterns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// buildCombinedSafetyRegexp unions every entry in patterns into one
+// compiled alternation, each wrapped in a named group pN (N = its index
+// in patterns), so a single FindAllStringSubmatchIndex pass finds every
+// pattern's matches at once instead of one pass per pattern.
+func buildCombinedSafetyRegexp(patterns []SafetyPattern) *regexp.Regexp {
+	parts := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		parts[i] = "(?P<p" + strconv.Itoa(i) + ">" + pattern.Pattern.String() + ")"
+	}
+	return regexp.MustCompile(strings.Join(parts, "|"))
+}
+
+// splitSafetyPatterns partitions safetyPatterns by RawContentOnly so the
+// two scan passes each get their own trie, combined regex, and index
+// space - see scanForSafetyMatches and scanForRawSafetyMatches.
+func splitSafetyPatterns(patterns []SafetyPattern, rawOnly bool) []SafetyPattern {
+	var out []SafetyPattern
+	for _, pattern := range patterns {
+		if pattern.RawContentOnly == rawOnly {
+			out = append(out, pattern)
+		}
+	}
+	return out
+}
+
+var (
+	normalizedSafetyPatterns = splitSafetyPatterns(safetyPatterns, false)
+	rawSafetyPatterns        = splitSafetyPatterns(safetyPatterns, true)
+
+	safetyKeywordTrie    = buildSafetyKeywordTrie(normalizedSafetyPatterns)
+	combinedSafetyRegexp = buildCombinedSafetyRegexp(normalizedSafetyPatterns)
+	combinedSafetyNames  = combinedSafetyRegexp.SubexpNames()
+
+	rawSafetyKeywordTrie    = buildSafetyKeywordTrie(rawSafetyPatterns)
+	combinedRawSafetyRegexp = buildCombinedSafetyRegexp(rawSafetyPatterns)
+	combinedRawSafetyNames  = combinedRawSafetyRegexp.SubexpNames()
+
+	// nonDigitPattern strips everything but digits out of a RequiresLuhn
+	// match before the checksum runs, since those patterns allow
+	// separators like "-" and "." between groups.
+	nonDigitPattern = regexp.MustCompile(`\D`)
+)
+
+// scanForSafetyMatches is what regexClassifier.Classify runs against
+// normalize()'s output: the Aho-Corasick pre-filter, then (only if it -
+// or a signal for one of the patterns with no useful literal keyword,
+// like "@" for the phrase-based doxxing patterns - means some pattern
+// could still match) the single combined-regex pass for exact spans.
+// Patterns whose shape normalize()'s leet/homoglyph folding would
+// corrupt (SafetyPattern.RawContentOnly) are excluded here and scanned
+// separately against raw content by scanForRawSafetyMatches.
+func scanForSafetyMatches(content string) []SafetyMatch {
+	return scanSafetyPatternGroup(content, normalizedSafetyPatterns, safetyKeywordTrie, combinedSafetyRegexp, combinedSafetyNames)
+}
+
+// scanForRawSafetyMatches is what regexClassifier.ClassifyRaw runs
+// against a message's original, unnormalized bytes: the digit-/"@"-
+// anchored identifier patterns (SSN, credit card, email, IPv4/IPv6,
+// IBAN) that normalize()'s leet-folding would otherwise destroy, e.g.
+// "078-05-1120" -> "ot8-os-ii2o" or "foo@bar.com" -> "fooabar.com".
+func scanForRawSafetyMatches(content string) []SafetyMatch {
+	return scanSafetyPatternGroup(content, rawSafetyPatterns, rawSafetyKeywordTrie, combinedRawSafetyRegexp, combinedRawSafetyNames)
+}
+
+// scanSafetyPatternGroup runs the Aho-Corasick prefilter plus combined-
+// regex pass for one scan group (patterns/trie/combined/names all built
+// from the same subset of safetyPatterns) against content.
+func scanSafetyPatternGroup(content string, patterns []SafetyPattern, trie *acTrie, combined *regexp.Regexp, names []string) []SafetyMatch {
+	lower := strings.ToLower(content)
+
+	if !trie.hasAnyKeyword(lower) &&
+		!strings.ContainsAny(content, "0123456789") &&
+		!strings.ContainsRune(content, '@') &&
+		!strings.ContainsRune(content, ':') {
+		return nil
+	}
+
+	var matches []SafetyMatch
+	for _, m := range combined.FindAllStringSubmatchIndex(content, -1) {
+		patternID, ok := winningSafetyPattern(m, names)
+		if !ok {
+			continue
+		}
+
+		pattern := patterns[patternID]
+		matchedText := content[m[0]:m[1]]
+
+		if pattern.RequiresLuhn && !luhnValid(nonDigitPattern.ReplaceAllString(matchedText, "")) {
+			continue
+		}
+
+		matches = append(matches, SafetyMatch{
+			Category:      pattern.Category,
+			Severity:      pattern.Severity,
+			MatchedText:   matchedText,
+			Start:         m[0],
+			End:           m[1],
+			Confidence:    1.0,
+			RedactionType: pattern.RedactionType,
+		})
+	}
+	return matches
+}
+
+// luhnValid reports whether digits (digit characters only) passes the
+// Luhn checksum, used to keep the credit-card pattern from false-
+// positiving on a 16-digit order or tracking number.
+func luhnValid(digits string) bool {
+	if digits == "" {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// winningSafetyPattern finds which pN named group participated in a
+// FindAllStringSubmatchIndex match and maps it back to its index in
+// whichever pattern slice names came from.
+func winningSafetyPattern(submatch []int, names []string) (int, bool) {
+	for i, name := range names {
+		if name == "" || submatch[2*i] == -1 {
+			continue
+		}
+		if !strings.HasPrefix(name, "p") {
+			continue
+		}
+		id, err := strconv.Atoi(name[1:])
+		if err != nil {
+			continue
+		}
+		return id, true
+	}
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// benchmarkCorpusMessages are the message shapes the benchmark corpus is
+// built from: plain chat, flagged content across several categories, and
+// the digit-/"@"-anchored identifiers that exercise scanForRawSafetyMatches.
+var benchmarkCorpusMessages = []string{
+	"just checking in, today was a pretty normal day at school",
+	"thanks for listening, I feel a bit better now",
+	"can we talk about something else, how was your weekend",
+	"I've been having trouble sleeping but nothing serious",
+	"my roommate and I are getting along better this week",
+	"I want to kill myself, I don't see the point anymore",
+	"thinking about cutting myself again tonight",
+	"he hit me again last night and I'm scared to go home",
+	"my phone number is 555-867-5309, call me at 5 tonight",
+	"my ssn is 078-05-1120, can you fix my account",
+	"card number 4111 1111 1111 1111 expires next year",
+	"reach me at foo@bar.com if you need anything",
+	"server lives at 192.168.1.42 behind the firewall",
+	"IBAN GB29NWBK60161331926819 for the reimbursement",
+}
+
+// naiveRegexScan reproduces the per-pattern loop regexClassifier.Classify
+// used before chunk2-3 replaced it with the Aho-Corasick prefilter and
+// combined regex in this file - the baseline the benchmark below compares
+// against.
+func naiveRegexScan(content string) []SafetyMatch {
+	var matches []SafetyMatch
+	for _, pattern := range safetyPatterns {
+		for _, match := range pattern.Pattern.FindAllStringIndex(content, -1) {
+			matches = append(matches, SafetyMatch{
+				Category:    pattern.Category,
+				Severity:    pattern.Severity,
+				MatchedText: content[match[0]:match[1]],
+				Start:       match[0],
+				End:         match[1],
+				Confidence:  1.0,
+			})
+		}
+	}
+	return matches
+}
+
+// buildBenchmarkCorpus returns a deterministic 10k-message corpus sampled
+// from benchmarkCorpusMessages, representative of the mix of clean and
+// flagged traffic scanForSafetyMatches runs against in production.
+func buildBenchmarkCorpus() []string {
+	r := rand.New(rand.NewSource(1))
+	corpus := make([]string, 10000)
+	for i := range corpus {
+		corpus[i] = benchmarkCorpusMessages[r.Intn(len(benchmarkCorpusMessages))]
+	}
+	return corpus
+}
+
+// BenchmarkNaiveRegexScan is the "old per-pattern loop" baseline: one
+// FindAllStringIndex call per safetyPatterns entry, per message.
+func BenchmarkNaiveRegexScan(b *testing.B) {
+	corpus := buildBenchmarkCorpus()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, msg := range corpus {
+			naiveRegexScan(msg)
+		}
+	}
+}
+
+// BenchmarkCombinedSafetyScan is the Aho-Corasick prefilter + combined
+// RE2 pass this file replaced it with - both scan groups, the same as
+// regexClassifier.Classify and .ClassifyRaw run per message in CheckContent.
+func BenchmarkCombinedSafetyScan(b *testing.B) {
+	corpus := buildBenchmarkCorpus()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, msg := range corpus {
+			scanForSafetyMatches(msg)
+			scanForRawSafetyMatches(msg)
+		}
+	}
+}
@@ -4,18 +4,38 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/storage/encstore"
 )
 
+// credentialStorePath is where seeded moderator credentials are written,
+// encrypted under SEED_ENCRYPTION_PASSWORD - see storage/encstore and
+// cmd/modcreds for reading them back.
+const credentialStorePath = "MODERATOR_CREDENTIALS.enc"
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
 
+	// Moderator passwords never sit on disk in plaintext, so refuse to
+	// run at all until there's a password to encrypt them under.
+	seedPassword := os.Getenv("SEED_ENCRYPTION_PASSWORD")
+	if seedPassword == "" {
+		log.Fatal("SEED_ENCRYPTION_PASSWORD must be set so seeded credentials can be encrypted at rest")
+	}
+
+	credStore, err := encstore.Open(credentialStorePath, seedPassword)
+	if err != nil {
+		log.Fatalf("Failed to open credential store: %v", err)
+	}
+
 	// Initialize Prisma client
 	client := db.NewClient()
 	if err := client.Prisma.Connect(); err != nil {
@@ -33,7 +53,7 @@ func main() {
 	fmt.Println("🗑️  Clearing existing users and all related data...")
 
 	// Delete all circle memberships first (due to foreign key constraints)
-	_, err := client.CircleMembership.FindMany().Delete().Exec(ctx)
+	_, err = client.CircleMembership.FindMany().Delete().Exec(ctx)
 	if err != nil {
 		log.Printf("Warning: Failed to delete circle memberships: %v", err)
 	} else {
@@ -108,10 +128,20 @@ func main() {
 			log.Fatalf("Failed to create moderator %s: %v", mod.fullName, err)
 		}
 
+		if err := credStore.Put(mod.email, encstore.Entry{
+			Role:      string(db.UserRoleModerator),
+			Email:     mod.email,
+			Phone:     mod.phoneNumber,
+			Password:  mod.password,
+			CreatedAt: time.Now().UTC(),
+		}); err != nil {
+			log.Fatalf("Failed to store credentials for %s: %v", mod.fullName, err)
+		}
+
 		fmt.Printf("   %d. Created: %s (%s)\n", i+1, user.FullName, user.Email)
 	}
 
 	fmt.Println("\n✅ Setup complete!")
-	fmt.Println("\n📝 Moderator credentials have been saved to MODERATOR_CREDENTIALS.md")
-	fmt.Println("   Please check the file for login details.")
+	fmt.Printf("\n📝 Moderator credentials have been encrypted and saved to %s\n", credentialStorePath)
+	fmt.Println("   Run cmd/modcreds to unlock and read a moderator's password.")
 }
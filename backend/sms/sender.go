@@ -0,0 +1,91 @@
+// Package sms provides the low-level transport for outbound text messages.
+// notifier.Sender calls down into a Sender here to actually deliver a
+// check-in text, so the transport can be swapped without touching callers.
+package sms
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Sender delivers a single text message.
+type Sender interface {
+	Send(to, body string) error
+}
+
+// NewFromEnv selects a Sender implementation based on SMS_PROVIDER
+// ("twilio", defaulting to a no-op sender when unset so local dev doesn't
+// need Twilio credentials to run).
+func NewFromEnv() Sender {
+	switch os.Getenv("SMS_PROVIDER") {
+	case "twilio":
+		return NewTwilioSender(
+			os.Getenv("TWILIO_ACCOUNT_SID"),
+			os.Getenv("TWILIO_AUTH_TOKEN"),
+			os.Getenv("TWILIO_FROM_NUMBER"),
+		)
+	default:
+		return &noopSender{}
+	}
+}
+
+// noopSender logs nothing and delivers nothing; it exists so SMS can stay
+// opt-in without every environment needing real Twilio credentials.
+type noopSender struct{}
+
+func (s *noopSender) Send(to, body string) error {
+	return fmt.Errorf("sms: no SMS provider configured")
+}
+
+// TwilioSender sends texts through the Twilio Messages REST API.
+type TwilioSender struct {
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+// NewTwilioSender builds a TwilioSender from explicit account details.
+func NewTwilioSender(accountSID, authToken, from string) *TwilioSender {
+	return &TwilioSender{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{},
+	}
+}
+
+func (s *TwilioSender) Send(to, body string) error {
+	if s.accountSID == "" || s.authToken == "" || s.from == "" {
+		return fmt.Errorf("sms: Twilio not configured")
+	}
+
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", s.accountSID)
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", s.from)
+	form.Set("Body", body)
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("sms: failed to build request: %w", err)
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms: Twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sms: Twilio returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package encstore is a small encrypted-at-rest key/value profile store
+// for secrets that have no business sitting in the database or in a
+// plaintext file - seed moderator credentials, operator-facing API keys,
+// anything a human needs to read once on a trusted machine. The whole
+// file is sealed with XChaCha20-Poly1305 under a key derived from a
+// single passphrase via Argon2id, so unlike crypto.EnvelopeCipher there's
+// no KMS/KEK to provision - just a password the operator remembers.
+package encstore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	saltSize  = 16
+	nonceSize = chacha20poly1305.NonceSizeX
+
+	// Argon2id parameters the request pins: time=4, memory=64MiB, threads=4.
+	argon2Time      = 4
+	argon2MemoryKiB = 64 * 1024
+	argon2Threads   = 4
+	argon2KeyLen    = 32
+)
+
+// ErrNotFound is returned by Get when no entry exists for the given key.
+var ErrNotFound = errors.New("encstore: entry not found")
+
+// Entry is one credential record in the store.
+type Entry struct {
+	Role      string    `json:"role"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Password  string    `json:"password"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// manifest is the JSON document sealed inside the store file.
+type manifest struct {
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Store is an encrypted profile store unlocked with a password. The
+// derived key and decrypted manifest only ever live in memory, for the
+// lifetime of the process holding the Store.
+type Store struct {
+	path     string
+	salt     []byte
+	key      []byte
+	manifest manifest
+}
+
+// Open unlocks the store file at path with password, creating an empty
+// store there if it doesn't exist yet. The file layout is
+// [16-byte salt][24-byte nonce][ciphertext||tag]: the salt derives an
+// Argon2id key from password, which seals a JSON manifest of Entry rows
+// under XChaCha20-Poly1305.
+func Open(path, password string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return create(path, password)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encstore: failed to read %s: %w", path, err)
+	}
+
+	if len(data) < saltSize+nonceSize {
+		return nil, errors.New("encstore: store file is truncated")
+	}
+
+	salt := data[:saltSize]
+	nonce := data[saltSize : saltSize+nonceSize]
+	sealed := data[saltSize+nonceSize:]
+	key := deriveKey(password, salt)
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("encstore: failed to init AEAD: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("encstore: wrong password or corrupt store")
+	}
+
+	var m manifest
+	if err := json.Unmarshal(plaintext, &m); err != nil {
+		return nil, fmt.Errorf("encstore: failed to parse manifest: %w", err)
+	}
+	if m.Entries == nil {
+		m.Entries = map[string]Entry{}
+	}
+
+	return &Store{path: path, salt: salt, key: key, manifest: m}, nil
+}
+
+func create(path, password string) (*Store, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("encstore: failed to generate salt: %w", err)
+	}
+
+	s := &Store{
+		path:     path,
+		salt:     salt,
+		key:      deriveKey(password, salt),
+		manifest: manifest{Entries: map[string]Entry{}},
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put adds or replaces the entry for key and persists the store.
+func (s *Store) Put(key string, entry Entry) error {
+	s.manifest.Entries[key] = entry
+	return s.save()
+}
+
+// Get returns the entry for key, or ErrNotFound.
+func (s *Store) Get(key string) (Entry, error) {
+	entry, ok := s.manifest.Entries[key]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+// List returns every key currently in the store, in no particular order.
+func (s *Store) List() []string {
+	keys := make([]string, 0, len(s.manifest.Entries))
+	for k := range s.manifest.Entries {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Rotate re-encrypts the store under newPassword with a freshly generated
+// salt, so the old password can no longer derive the key that unlocks it.
+func (s *Store) Rotate(newPassword string) error {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("encstore: failed to generate salt: %w", err)
+	}
+	s.salt = salt
+	s.key = deriveKey(newPassword, salt)
+	return s.save()
+}
+
+func (s *Store) save() error {
+	plaintext, err := json.Marshal(s.manifest)
+	if err != nil {
+		return fmt.Errorf("encstore: failed to encode manifest: %w", err)
+	}
+
+	aead, err := chacha20poly1305.NewX(s.key)
+	if err != nil {
+		return fmt.Errorf("encstore: failed to init AEAD: %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("encstore: failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nil, nonce, plaintext, nil)
+
+	out := make([]byte, 0, saltSize+len(nonce)+len(sealed))
+	out = append(out, s.salt...)
+	out = append(out, nonce...)
+	out = append(out, sealed...)
+
+	return os.WriteFile(s.path, out, 0600)
+}
+
+func deriveKey(password string, salt []byte) []byte {
+	return argon2.IDKey([]byte(password), salt, argon2Time, argon2MemoryKiB, argon2Threads, argon2KeyLen)
+}
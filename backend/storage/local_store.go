@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalStore persists objects to a directory on local disk, the default
+// Store when no S3-compatible bucket is configured so local dev doesn't
+// need bucket credentials to run.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore builds a LocalStore rooted at dir, created on first Put if
+// it doesn't already exist.
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{dir: dir}
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("storage: failed to create directory for %s: %w", key, err)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("storage: failed to create %s: %w", key, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, body); err != nil {
+		return fmt.Errorf("storage: failed to write %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// PresignGet always returns false - local disk has no notion of a
+// time-limited URL, so callers fall back to proxying through Get.
+func (s *LocalStore) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, bool, error) {
+	return "", false, nil
+}
@@ -0,0 +1,50 @@
+// Package storage provides the low-level transport for message image
+// uploads. handlers.MessageHandler does the decoding, EXIF stripping, and
+// thumbnailing; it calls down into a Store here just to persist and later
+// serve the resulting bytes, so the backend (local disk in dev, an
+// S3-compatible bucket in production) can be swapped without touching the
+// handler.
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// Store persists and serves the objects UploadImage produces: the
+// processed original and its thumbnail, each addressed by an opaque key.
+type Store interface {
+	// Put uploads body (exactly size bytes) under key with the given
+	// content type.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Get opens key for reading. The caller must close the returned
+	// ReadCloser.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// PresignGet returns a time-limited URL the caller can serve a
+	// redirect to instead of proxying the bytes itself, or ("", false) if
+	// this Store doesn't support presigning (LocalStore, say).
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, bool, error)
+}
+
+// NewFromEnv selects a Store implementation based on STORAGE_PROVIDER
+// ("s3", defaulting to local disk so dev doesn't need bucket credentials
+// to run).
+func NewFromEnv() (Store, error) {
+	switch os.Getenv("STORAGE_PROVIDER") {
+	case "s3":
+		return NewS3Store(
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_ACCESS_KEY_ID"),
+			os.Getenv("S3_SECRET_ACCESS_KEY"),
+			os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		)
+	default:
+		return NewLocalStore("./uploads/messages"), nil
+	}
+}
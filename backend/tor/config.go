@@ -0,0 +1,33 @@
+// Package tor publishes the API as a v3 onion service so peer-support
+// users on hostile networks can reach their circle without clear-net
+// metadata ever being observable. It doesn't touch auth: the onion
+// service is just another listener in front of the same Gin router and
+// Socket.io handlers, so the JWT flow is unchanged end to end.
+package tor
+
+import "os"
+
+// Config controls whether and how the onion service starts.
+type Config struct {
+	// Enabled turns the onion listener on. Everything else in this
+	// package is a no-op when this is false.
+	Enabled bool
+	// ControlPort is the Tor control port to connect to. When empty, an
+	// embedded Tor process is started instead of reusing an existing one.
+	ControlPort string
+	// DataDir is where the embedded Tor process keeps its state.
+	DataDir string
+	// OnionKeyPath is where the v3 onion service's private key is
+	// persisted, so the .onion address stays stable across restarts.
+	OnionKeyPath string
+}
+
+// NewConfigFromEnv reads onion-service configuration from the environment.
+func NewConfigFromEnv() Config {
+	return Config{
+		Enabled:      os.Getenv("TOR_ENABLED") == "true",
+		ControlPort:  os.Getenv("TOR_CONTROL_PORT"),
+		DataDir:      os.Getenv("TOR_DATA_DIR"),
+		OnionKeyPath: os.Getenv("TOR_ONION_KEY_PATH"),
+	}
+}
@@ -0,0 +1,151 @@
+package tor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cretz/bine/tor"
+)
+
+// onionServiceTimeout bounds how long we wait for the onion service's
+// descriptor to publish before giving up on startup.
+const onionServiceTimeout = 3 * time.Minute
+
+// Service owns the embedded (or control-port-attached) Tor process and the
+// v3 onion service that forwards to the local Gin listener.
+type Service struct {
+	cfg     Config
+	tor     *tor.Tor
+	onion   *tor.OnionService
+	address string
+}
+
+// NewService starts Tor (embedding it unless cfg.ControlPort is set, in
+// which case an already-running daemon is reused) and publishes a v3
+// onion service at localPort. It returns (nil, nil) when cfg.Enabled is
+// false, so callers can construct it unconditionally at startup.
+func NewService(ctx context.Context, cfg Config, localPort int) (*Service, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	startConf := &tor.StartConf{
+		DataDir: cfg.DataDir,
+	}
+	if cfg.ControlPort != "" {
+		startConf.ControlPort = cfg.ControlPort
+	}
+
+	t, err := tor.Start(ctx, startConf)
+	if err != nil {
+		return nil, fmt.Errorf("tor: failed to start: %w", err)
+	}
+
+	listenCtx, cancel := context.WithTimeout(ctx, onionServiceTimeout)
+	defer cancel()
+
+	onionKey, err := loadOrCreateOnionKey(cfg.OnionKeyPath)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("tor: failed to load onion key: %w", err)
+	}
+
+	onion, err := t.Listen(listenCtx, &tor.ListenConf{
+		Key:         onionKey,
+		Version3:    true,
+		RemotePorts: []int{80},
+	})
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("tor: failed to publish onion service: %w", err)
+	}
+
+	go forward(onion, localPort)
+
+	return &Service{
+		cfg:     cfg,
+		tor:     t,
+		onion:   onion,
+		address: onion.ID + ".onion",
+	}, nil
+}
+
+// Address returns the published .onion hostname (no scheme or port).
+func (s *Service) Address() string {
+	if s == nil {
+		return ""
+	}
+	return s.address
+}
+
+// Close tears down the onion service and the Tor process it was
+// published through.
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	if err := s.onion.Close(); err != nil {
+		return err
+	}
+	return s.tor.Close()
+}
+
+// forward pipes every onion-service connection to the local Gin listener,
+// so the same HTTP/Socket.io stack serves both clear-net and onion
+// clients without the handlers knowing the difference.
+func forward(onion *tor.OnionService, localPort int) {
+	for {
+		conn, err := onion.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(c net.Conn) {
+			defer c.Close()
+
+			local, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+			if err != nil {
+				return
+			}
+			defer local.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				_, _ = io.Copy(local, c)
+				done <- struct{}{}
+			}()
+			go func() {
+				_, _ = io.Copy(c, local)
+				done <- struct{}{}
+			}()
+			<-done
+		}(conn)
+	}
+}
+
+func loadOrCreateOnionKey(path string) (tor.OnionKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return tor.ParseV3PrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, data, err := tor.GenerateV3Key()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
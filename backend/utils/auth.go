@@ -1,8 +1,8 @@
 package utils
 
 import (
-	"errors"
-	"os"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -30,11 +30,32 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a JWT token for a user
+var (
+	issuerOnce    sync.Once
+	issuerDefault TokenIssuer
+	issuerErr     error
+)
+
+// defaultIssuer lazily builds the process-wide TokenIssuer from the
+// environment the first time a token needs signing or verifying, so the
+// WebSocket auth path and every handler share the same rotating keyset
+// without each needing it threaded through their constructor.
+func defaultIssuer() (TokenIssuer, error) {
+	issuerOnce.Do(func() {
+		issuerDefault, issuerErr = NewRotatingIssuerFromEnv()
+		if issuerErr == nil {
+			log.Printf("utils: JWT issuer ready (alg=%s)", issuerDefault.(*RotatingIssuer).alg)
+		}
+	})
+	return issuerDefault, issuerErr
+}
+
+// GenerateJWT generates a JWT token for a user, signed by the active key
+// in the process's TokenIssuer.
 func GenerateJWT(userID, email, role string, onboardingCompleted bool) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "", errors.New("JWT_SECRET not set")
+	issuer, err := defaultIssuer()
+	if err != nil {
+		return "", err
 	}
 
 	claims := JWTClaims{
@@ -48,30 +69,28 @@ func GenerateJWT(userID, email, role string, onboardingCompleted bool) (string,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return issuer.Issue(claims)
 }
 
-// ValidateJWT validates a JWT token and returns the claims
+// ValidateJWT validates a JWT token and returns the claims, picking the
+// verification key by the kid stamped into the token's header so it
+// keeps working across a key rotation.
 func ValidateJWT(tokenString string) (*JWTClaims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, errors.New("JWT_SECRET not set")
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-
+	issuer, err := defaultIssuer()
 	if err != nil {
 		return nil, err
 	}
+	return issuer.Verify(tokenString)
+}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+// JWKS returns the process's current public keyset, for the
+// /.well-known/jwks.json endpoint.
+func JWKS() (JWKSDocument, error) {
+	issuer, err := defaultIssuer()
+	if err != nil {
+		return JWKSDocument{}, err
 	}
-
-	return nil, errors.New("invalid token")
+	return issuer.JWKS(), nil
 }
 
 // ValidatePasswordStrength validates password strength
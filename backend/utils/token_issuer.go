@@ -0,0 +1,488 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxTokenTTL is how long a retired signing key must still be kept around
+// to verify tokens it already signed; it matches GenerateJWT's token
+// lifetime, so a key is safe to drop once every token it could have
+// signed has expired on its own.
+const maxTokenTTL = 7 * 24 * time.Hour
+
+// defaultRotationInterval is how often the active signing key changes
+// when JWT_KEY_ROTATION_INTERVAL isn't set.
+const defaultRotationInterval = 24 * time.Hour
+
+// ErrKeyNotFound is returned by Verify when a token's kid doesn't match
+// any key still held in the keyset (expired and already retired, or
+// never issued by this service).
+var ErrKeyNotFound = errors.New("utils: no signing key for token's kid")
+
+// TokenIssuer signs and verifies the app's JWTs. Implementations publish
+// their current public keys as a JWKS document so other services (a
+// future moderator dashboard, say) can verify a token without the shared
+// secret, and so the WebSocket auth path and REST middleware can share a
+// single trust anchor.
+type TokenIssuer interface {
+	Issue(claims JWTClaims) (string, error)
+	Verify(tokenString string) (*JWTClaims, error)
+	JWKS() JWKSDocument
+}
+
+// JWKSDocument is a minimal RFC 7517 JSON Web Key Set.
+type JWKSDocument struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the subset of RFC 7517 fields needed to publish RSA and Ed25519
+// (OKP) public keys. HS256 keys are symmetric and are never published.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type signingKey struct {
+	kid        string
+	createdAt  time.Time
+	privateKey interface{}
+	publicKey  interface{} // nil for HS256, where privateKey doubles as the verification secret
+}
+
+// RotatingIssuer implements TokenIssuer over a keyset that rotates its
+// active signing key on a timer and retires a key once every token it
+// could have signed is past its own expiry.
+type RotatingIssuer struct {
+	alg      string
+	method   jwt.SigningMethod
+	keyDir   string
+	rotation time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]*signingKey
+	activeKid string
+}
+
+// NewRotatingIssuerFromEnv builds a RotatingIssuer from the environment:
+// JWT_ALG selects HS256 (default, matching prior behavior), RS256, or
+// EdDSA; JWT_KEY_DIR is where generated RS256/EdDSA keys persist across
+// restarts (defaults to an in-memory-only keyset, which loses validity
+// across a restart - fine for local dev, not for production);
+// JWT_KEY_ROTATION_INTERVAL is a Go duration string (default 24h). HS256
+// doesn't use JWT_KEY_DIR at all: it's seeded straight from JWT_SECRET
+// (required), the same stable shared secret every instance signs and
+// verifies with, exactly as it worked before key rotation existed. It
+// loads any existing RS256/EdDSA keys from JWT_KEY_DIR, mints a fresh
+// active key if none exist, and starts the rotation goroutine.
+func NewRotatingIssuerFromEnv() (*RotatingIssuer, error) {
+	alg := os.Getenv("JWT_ALG")
+	if alg == "" {
+		alg = "HS256"
+	}
+
+	method, err := signingMethodFor(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := defaultRotationInterval
+	if raw := os.Getenv("JWT_KEY_ROTATION_INTERVAL"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("utils: invalid JWT_KEY_ROTATION_INTERVAL: %w", err)
+		}
+		rotation = parsed
+	}
+
+	issuer := &RotatingIssuer{
+		alg:      alg,
+		method:   method,
+		keyDir:   os.Getenv("JWT_KEY_DIR"),
+		rotation: rotation,
+		keys:     make(map[string]*signingKey),
+	}
+
+	if issuer.alg != "HS256" && issuer.keyDir != "" {
+		if err := issuer.loadKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	if issuer.activeKid == "" {
+		if err := issuer.mintKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	go issuer.rotateForever()
+
+	return issuer, nil
+}
+
+// Issue signs claims with the active key and stamps its kid into the
+// token header, so Verify can later pick the right key even after
+// several rotations have happened.
+func (ri *RotatingIssuer) Issue(claims JWTClaims) (string, error) {
+	ri.mu.RLock()
+	active, ok := ri.keys[ri.activeKid]
+	kid := ri.activeKid
+	ri.mu.RUnlock()
+	if !ok {
+		return "", errors.New("utils: no active signing key")
+	}
+
+	token := jwt.NewWithClaims(ri.method, claims)
+	token.Header["kid"] = kid
+
+	if ri.alg == "HS256" {
+		return token.SignedString(active.privateKey.([]byte))
+	}
+	return token.SignedString(active.privateKey)
+}
+
+// Verify checks tokenString's signature against the key its header names
+// by kid (falling back to the active key if the header has none, for
+// tokens issued before this package tracked kids) and returns its claims.
+func (ri *RotatingIssuer) Verify(tokenString string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		ri.mu.RLock()
+		defer ri.mu.RUnlock()
+
+		if kid == "" {
+			kid = ri.activeKid
+		}
+
+		key, ok := ri.keys[kid]
+		if !ok {
+			return nil, ErrKeyNotFound
+		}
+
+		if ri.alg == "HS256" {
+			return key.privateKey.([]byte), nil
+		}
+		return key.publicKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWKS returns the current keyset's public keys. HS256 keys are
+// symmetric and are omitted - there's nothing safe to publish for them.
+func (ri *RotatingIssuer) JWKS() JWKSDocument {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+
+	doc := JWKSDocument{Keys: []JWK{}}
+	for _, key := range ri.keys {
+		jwk, ok := publicJWK(ri.alg, key)
+		if ok {
+			doc.Keys = append(doc.Keys, jwk)
+		}
+	}
+	return doc
+}
+
+// rotateForever mints a new active key every rotation interval and
+// retires any key whose possible tokens have all expired. It's meant to
+// run for the lifetime of the process in its own goroutine.
+func (ri *RotatingIssuer) rotateForever() {
+	ticker := time.NewTicker(ri.rotation)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ri.mintKey(); err != nil {
+			log.Printf("utils: failed to rotate signing key: %v", err)
+			continue
+		}
+		ri.retireExpiredKeys()
+	}
+}
+
+func (ri *RotatingIssuer) retireExpiredKeys() {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxTokenTTL)
+	for kid, key := range ri.keys {
+		if kid != ri.activeKid && key.createdAt.Before(cutoff) {
+			delete(ri.keys, kid)
+			if ri.keyDir != "" {
+				_ = os.Remove(ri.keyFilePath(kid, key.createdAt))
+			}
+		}
+	}
+}
+
+func (ri *RotatingIssuer) mintKey() error {
+	if ri.alg == "HS256" {
+		return ri.mintHS256Key()
+	}
+
+	kid, err := GenerateURLSafeToken()
+	if err != nil {
+		return fmt.Errorf("utils: failed to generate kid: %w", err)
+	}
+
+	key := &signingKey{kid: kid, createdAt: time.Now().UTC()}
+
+	switch ri.alg {
+	case "RS256":
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return fmt.Errorf("utils: failed to generate RSA key: %w", err)
+		}
+		key.privateKey = priv
+		key.publicKey = &priv.PublicKey
+	case "EdDSA":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("utils: failed to generate Ed25519 key: %w", err)
+		}
+		key.privateKey = priv
+		key.publicKey = pub
+	default:
+		return fmt.Errorf("utils: unsupported JWT_ALG %q", ri.alg)
+	}
+
+	if ri.keyDir != "" {
+		if err := ri.persistKey(key); err != nil {
+			return err
+		}
+	}
+
+	ri.mu.Lock()
+	ri.keys[kid] = key
+	ri.activeKid = kid
+	ri.mu.Unlock()
+
+	return nil
+}
+
+// mintHS256Key seeds the HS256 signing key from JWT_SECRET instead of
+// generating a fresh random one, so it stays the same stable secret
+// across restarts and is shared identically by every instance - a
+// random per-boot secret would silently invalidate every outstanding
+// token on each restart/deploy and couldn't verify another instance's
+// tokens. Its kid is derived from the secret rather than random, so a
+// restart or a rotation tick (HS256 has nothing to rotate to) resolves
+// to the same keyset entry instead of piling up duplicates.
+func (ri *RotatingIssuer) mintHS256Key() error {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return errors.New("utils: JWT_SECRET not set")
+	}
+
+	kid := hs256Kid(secret)
+
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	if _, ok := ri.keys[kid]; !ok {
+		ri.keys[kid] = &signingKey{kid: kid, createdAt: time.Now().UTC(), privateKey: []byte(secret)}
+	}
+	ri.activeKid = kid
+
+	return nil
+}
+
+// hs256Kid deterministically derives a kid from an HS256 secret, so
+// every instance sharing JWT_SECRET agrees on the same kid without
+// coordinating one out of band.
+func hs256Kid(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "hs256-" + hex.EncodeToString(sum[:8])
+}
+
+func (ri *RotatingIssuer) persistKey(key *signingKey) error {
+	if err := os.MkdirAll(ri.keyDir, 0700); err != nil {
+		return fmt.Errorf("utils: failed to create JWT_KEY_DIR: %w", err)
+	}
+
+	var block *pem.Block
+	switch priv := key.privateKey.(type) {
+	case []byte:
+		block = &pem.Block{Type: "JWT HS256 KEY", Bytes: priv}
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}
+	case ed25519.PrivateKey:
+		block = &pem.Block{Type: "ED25519 PRIVATE KEY", Bytes: priv}
+	default:
+		return fmt.Errorf("utils: don't know how to persist key of type %T", priv)
+	}
+
+	return os.WriteFile(ri.keyFilePath(key.kid, key.createdAt), pem.EncodeToMemory(block), 0600)
+}
+
+// keyFilePath encodes createdAt into the filename so a restart can
+// recover each key's age (and therefore its retirement date) without a
+// separate metadata file.
+func (ri *RotatingIssuer) keyFilePath(kid string, createdAt time.Time) string {
+	return filepath.Join(ri.keyDir, fmt.Sprintf("%d-%s.pem", createdAt.Unix(), kid))
+}
+
+func (ri *RotatingIssuer) loadKeys() error {
+	entries, err := os.ReadDir(ri.keyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("utils: failed to read JWT_KEY_DIR: %w", err)
+	}
+
+	type loaded struct {
+		kid       string
+		createdAt time.Time
+	}
+	var order []loaded
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+
+		kid, createdAt, ok := parseKeyFileName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(ri.keyDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("utils: failed to read key file %s: %w", entry.Name(), err)
+		}
+
+		key, err := decodeKey(ri.alg, kid, createdAt, data)
+		if err != nil {
+			return fmt.Errorf("utils: failed to decode key file %s: %w", entry.Name(), err)
+		}
+
+		ri.keys[kid] = key
+		order = append(order, loaded{kid: kid, createdAt: createdAt})
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i].createdAt.Before(order[j].createdAt) })
+	if len(order) > 0 {
+		ri.activeKid = order[len(order)-1].kid
+	}
+
+	return nil
+}
+
+func parseKeyFileName(name string) (kid string, createdAt time.Time, ok bool) {
+	base := strings.TrimSuffix(name, ".pem")
+	parts := strings.SplitN(base, "-", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}, false
+	}
+
+	unixSeconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return parts[1], time.Unix(unixSeconds, 0).UTC(), true
+}
+
+func decodeKey(alg, kid string, createdAt time.Time, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &signingKey{kid: kid, createdAt: createdAt}
+
+	switch alg {
+	case "HS256":
+		key.privateKey = block.Bytes
+	case "RS256":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.privateKey = priv
+		key.publicKey = &priv.PublicKey
+	case "EdDSA":
+		if len(block.Bytes) != ed25519.PrivateKeySize {
+			return nil, errors.New("unexpected Ed25519 key size")
+		}
+		priv := ed25519.PrivateKey(block.Bytes)
+		key.privateKey = priv
+		key.publicKey = priv.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %q", alg)
+	}
+
+	return key, nil
+}
+
+func signingMethodFor(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case "HS256":
+		return jwt.SigningMethodHS256, nil
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("utils: unsupported JWT_ALG %q", alg)
+	}
+}
+
+func publicJWK(alg string, key *signingKey) (JWK, bool) {
+	switch pub := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: key.kid,
+			Alg: alg,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Use: "sig",
+			Kid: key.kid,
+			Alg: alg,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const tokenCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// tokenLength matches the 20-character URL-safe tokens used for password
+// reset and email verification links.
+const tokenLength = 20
+
+// GenerateURLSafeToken returns a random 20-character URL-safe token
+// suitable for embedding directly in a reset/verification link.
+func GenerateURLSafeToken() (string, error) {
+	raw := make([]byte, tokenLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	token := make([]byte, tokenLength)
+	for i, b := range raw {
+		token[i] = tokenCharset[int(b)%len(tokenCharset)]
+	}
+
+	return string(token), nil
+}
+
+// HashToken returns the SHA-256 hash of a token, hex-encoded. Reset and
+// verification tokens are stored hashed so a database read alone never
+// grants the ability to reset a password or verify an email.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
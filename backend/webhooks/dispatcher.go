@@ -0,0 +1,272 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"mindbridge/backend/prisma/db"
+)
+
+// Event names a dispatcher fires. Institutions subscribe a webhook to one
+// or more of these when they register an endpoint.
+const (
+	EventCrisisDetected       = "crisis.detected"
+	EventCircleCreated        = "circle.created"
+	EventCircleMatched        = "circle.matched"
+	EventCircleOverCapacity   = "circle.over_capacity"
+	EventUserOnboarded        = "user.onboarded"
+	EventMessageCreated       = "message.created"
+	EventRiskCritical         = "risk.critical"
+	EventInstantHelpEscalated = "instant_help.escalated"
+
+	// EventWebhookTest is the canned event POSTed by /webhooks/:id/test so
+	// campus counseling IT can validate an endpoint before a real crisis
+	// alert ever depends on it.
+	EventWebhookTest = "webhook.test"
+)
+
+// retryBackoff is the fixed redelivery schedule. Once it's exhausted the
+// delivery is written to the dead-letter table instead of tried again.
+var retryBackoff = []time.Duration{
+	1 * time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	5 * time.Minute,
+	30 * time.Minute,
+}
+
+// Dispatcher fires signed, sanitized event notifications at the HTTP
+// endpoints an institution has registered, so it can plug MindBridge into
+// its own incident system without ever being given DB access.
+type Dispatcher struct {
+	client     *db.PrismaClient
+	httpClient *http.Client
+}
+
+func NewDispatcher(client *db.PrismaClient) *Dispatcher {
+	return &Dispatcher{
+		client: client,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// eventPayload is the body POSTed to subscribers. It intentionally carries
+// only the user ID and event metadata, never free-text chat content, so a
+// compromised or misconfigured endpoint never leaks anything sensitive.
+type eventPayload struct {
+	Event     string `json:"event"`
+	UserID    string `json:"userId"`
+	Nonce     string `json:"nonce"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Fire looks up every active webhook subscribed to event and delivers it
+// asynchronously, retrying with backoff on failure.
+func (d *Dispatcher) Fire(ctx context.Context, event, userID string) {
+	hooks, err := d.client.Webhook.FindMany(
+		db.Webhook.Events.HasSome([]string{event}),
+		db.Webhook.Active.Equals(true),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("webhooks: failed to load subscribers for %s: %v", event, err)
+		return
+	}
+
+	for i := range hooks {
+		go d.deliver(hooks[i], event, userID)
+	}
+}
+
+// FireTest delivers a canned EventWebhookTest event directly to hookID,
+// bypassing the event-subscription lookup Fire does.
+func (d *Dispatcher) FireTest(ctx context.Context, hookID string) error {
+	hook, err := d.client.Webhook.FindUnique(
+		db.Webhook.ID.Equals(hookID),
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	go d.deliver(*hook, EventWebhookTest, "test-user")
+	return nil
+}
+
+func (d *Dispatcher) deliver(hook db.WebhookModel, event, userID string) {
+	nonce, err := randomNonce()
+	if err != nil {
+		log.Printf("webhooks: failed to generate nonce for webhook %s: %v", hook.ID, err)
+		return
+	}
+
+	payload := eventPayload{
+		Event:     event,
+		UserID:    userID,
+		Nonce:     nonce,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("webhooks: failed to marshal payload for webhook %s: %v", hook.ID, err)
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		if d.send(hook, body) {
+			d.recordDelivery(context.Background(), hook.ID, event, string(body), db.WebhookDeliveryStatusDelivered)
+			return
+		}
+
+		if attempt >= len(retryBackoff) {
+			log.Printf("webhooks: exhausted retries for webhook %s event %s, moving to dead letter", hook.ID, event)
+			d.recordDelivery(context.Background(), hook.ID, event, string(body), db.WebhookDeliveryStatusDeadLetter)
+			return
+		}
+
+		time.Sleep(retryBackoff[attempt])
+	}
+}
+
+// send delivers body to hook, formatted for whatever Kind it is. Generic
+// webhooks get the canonical signed JSON event; Telegram and Discord get
+// the same event reformatted into each platform's own message shape, since
+// neither one is a subscriber endpoint that verifies our signature.
+func (d *Dispatcher) send(hook db.WebhookModel, body []byte) bool {
+	switch hook.Kind {
+	case db.WebhookKindTelegram:
+		return d.sendTelegram(hook, body)
+	case db.WebhookKindDiscord:
+		return d.sendDiscord(hook, body)
+	default:
+		return d.sendGeneric(hook, body)
+	}
+}
+
+// sendGeneric POSTs the canonical signed JSON event payload, the shape
+// every institution-owned subscriber endpoint is expected to verify and
+// parse.
+func (d *Dispatcher) sendGeneric(hook db.WebhookModel, body []byte) bool {
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("webhooks: failed to build request for webhook %s: %v", hook.ID, err)
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-MindBridge-Timestamp", timestamp)
+	req.Header.Set("X-MindBridge-Signature", "sha256="+sign(hook.Secret, timestamp, body))
+
+	return d.do(hook.ID, req)
+}
+
+// sendTelegram reformats the event as a bot sendMessage call. hook.URL is
+// expected to be the bot's full sendMessage endpoint
+// (https://api.telegram.org/bot<token>/sendMessage) with the target
+// chat_id already appended as a query parameter, the same way a Discord
+// incoming webhook URL self-describes its destination channel.
+func (d *Dispatcher) sendTelegram(hook db.WebhookModel, body []byte) bool {
+	var event eventPayload
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.Printf("webhooks: failed to decode event for telegram webhook %s: %v", hook.ID, err)
+		return false
+	}
+
+	form := url.Values{"text": {formatEventMessage(event)}}
+	req, err := http.NewRequest(http.MethodPost, hook.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Printf("webhooks: failed to build telegram request for webhook %s: %v", hook.ID, err)
+		return false
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return d.do(hook.ID, req)
+}
+
+// sendDiscord reformats the event as a Discord incoming-webhook message.
+// hook.URL is the channel's full webhook URL.
+func (d *Dispatcher) sendDiscord(hook db.WebhookModel, body []byte) bool {
+	var event eventPayload
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.Printf("webhooks: failed to decode event for discord webhook %s: %v", hook.ID, err)
+		return false
+	}
+
+	discordBody, err := json.Marshal(map[string]string{"content": formatEventMessage(event)})
+	if err != nil {
+		log.Printf("webhooks: failed to marshal discord payload for webhook %s: %v", hook.ID, err)
+		return false
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(discordBody))
+	if err != nil {
+		log.Printf("webhooks: failed to build discord request for webhook %s: %v", hook.ID, err)
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return d.do(hook.ID, req)
+}
+
+// formatEventMessage renders an eventPayload as the plain-text message
+// Telegram and Discord expect, since neither reads the canonical JSON
+// shape generic subscribers do.
+func formatEventMessage(event eventPayload) string {
+	return fmt.Sprintf("MindBridge event: %s\nUser: %s\nAt: %s", event.Event, event.UserID, event.Timestamp)
+}
+
+func (d *Dispatcher) do(hookID string, req *http.Request) bool {
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		log.Printf("webhooks: delivery to webhook %s failed: %v", hookID, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func (d *Dispatcher) recordDelivery(ctx context.Context, webhookID, event, payload string, status db.WebhookDeliveryStatus) {
+	_, err := d.client.WebhookDelivery.CreateOne(
+		db.WebhookDelivery.Webhook.Link(db.Webhook.ID.Equals(webhookID)),
+		db.WebhookDelivery.Event.Set(event),
+		db.WebhookDelivery.Payload.Set(payload),
+		db.WebhookDelivery.Status.Set(status),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("webhooks: failed to record delivery for webhook %s: %v", webhookID, err)
+	}
+}
+
+// sign returns the hex-encoded HMAC-SHA256 of timestamp+"."+body, keyed by
+// the webhook's secret. Binding the timestamp into the signature (not just
+// sending it alongside) stops a captured payload from being replayed
+// against a subscriber verbatim with a forged, stale timestamp.
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomNonce() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
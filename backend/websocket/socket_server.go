@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -14,20 +15,118 @@ import (
 	"github.com/googollee/go-socket.io/engineio/transport"
 	"github.com/googollee/go-socket.io/engineio/transport/polling"
 	transportWebsocket "github.com/googollee/go-socket.io/engineio/transport/websocket"
+	"mindbridge/backend/crypto"
 	"mindbridge/backend/prisma/db"
+	"mindbridge/backend/services"
 	"mindbridge/backend/utils"
+	"mindbridge/backend/webhooks"
 )
 
 type SocketServer struct {
-	server *socketio.Server
-	client *db.PrismaClient
+	server     *socketio.Server
+	client     *db.PrismaClient
+	cipher     *crypto.EnvelopeCipher
+	bridge     MatrixBridge
+	e2ee       *services.E2EEService
+	dispatcher *webhooks.Dispatcher
+}
+
+// MatrixBridge is implemented by an optional bridge that mirrors circle
+// chat activity onto Matrix. SocketServer depends only on this narrow
+// interface so it doesn't need to import the matrix package directly.
+type MatrixBridge interface {
+	OnNewMessage(ctx context.Context, circleID, senderID, senderName, content string)
+	OnMessageRead(circleID, messageID, userID string)
+	OnTyping(circleID, userID string, typing bool)
+}
+
+// moderatorsRoom is a global room every moderator's connection joins on
+// authentication, so on-call staff see crisis escalations immediately
+// instead of polling for them.
+const moderatorsRoom = "moderators"
+
+const (
+	// resumeBackfillWindow bounds how far back a reconnect replays missed
+	// messages, so a user who hasn't opened the app in months doesn't
+	// trigger an unbounded backfill.
+	resumeBackfillWindow = 7 * 24 * time.Hour
+	// resumeBackfillLimit caps the same backfill by message count,
+	// whichever bound is tighter.
+	resumeBackfillLimit = 200
+)
+
+// Metrics is a snapshot of the socket server's live connection count,
+// intended to be scraped by a monitoring agent.
+type Metrics struct {
+	ActiveConnections int `json:"activeConnections"`
+}
+
+// CrisisAlertPayload is broadcast to the moderators room the moment
+// MatchUserToCircle flags a user as critical risk.
+type CrisisAlertPayload struct {
+	UserID    string `json:"userId"`
+	Phq2Total int    `json:"phq2Total"`
+	Gad2Total int    `json:"gad2Total"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 type MessagePayload struct {
-	CircleID  string `json:"circleId"`
-	Content   string `json:"content"`
-	ImageURL  string `json:"imageUrl,omitempty"`
+	CircleID string `json:"circleId"`
+	Content  string `json:"content"`
+	// ImageID is the id MessageHandler.UploadImage returned for a
+	// previously-uploaded image, linked to this message on creation. A
+	// message carries at most one image.
+	ImageID   string `json:"imageId,omitempty"`
 	Timestamp int64  `json:"timestamp"`
+	// Counter is the sender's per-circle chain-key counter this message
+	// was encrypted at, present only when the circle is end-to-end
+	// encrypted - it lets recipients derive the matching message key
+	// without the server ever seeing plaintext or key material.
+	Counter *uint32 `json:"counter,omitempty"`
+	// Epoch is the key epoch the sender's chain key was current for when
+	// they encrypted Content, present only on end-to-end encrypted
+	// circles. The server rejects a mismatch against the circle's current
+	// CircleSession instead of storing a message no member could derive
+	// the key for.
+	Epoch *int `json:"epoch,omitempty"`
+}
+
+// KeyBundleUploadPayload publishes a member's long-lived X25519 identity
+// key plus, optionally, the sealed chain-key distributions that key
+// encrypts to each of the circle's other members for the current epoch.
+type KeyBundleUploadPayload struct {
+	CircleID          string                   `json:"circleId"`
+	IdentityPublicKey string                   `json:"identityPublicKey"`
+	Distributions     []SenderKeyDistributionIn `json:"distributions,omitempty"`
+}
+
+// SenderKeyDistributionIn is one nacl/box-sealed chain key addressed to a
+// single recipient, as uploaded by the sender who generated it.
+type SenderKeyDistributionIn struct {
+	ToUserID        string `json:"toUserId"`
+	SealedChainKey  string `json:"sealedChainKey"`
+	Epoch           int    `json:"epoch"`
+}
+
+// KeyBundlePayload is what key_bundle_request resolves to: every current
+// member's identity key, plus whatever sealed distributions are addressed
+// to the requester for the circle's current epoch.
+type KeyBundlePayload struct {
+	CircleID      string                     `json:"circleId"`
+	Epoch         int                        `json:"epoch"`
+	IdentityKeys  []IdentityKeyEntry         `json:"identityKeys"`
+	Distributions []SenderKeyDistributionOut `json:"distributions"`
+}
+
+type IdentityKeyEntry struct {
+	UserID            string `json:"userId"`
+	IdentityPublicKey string `json:"identityPublicKey"`
+}
+
+type SenderKeyDistributionOut struct {
+	FromUserID     string `json:"fromUserId"`
+	SealedChainKey string `json:"sealedChainKey"`
+	Epoch          int    `json:"epoch"`
 }
 
 type ReadReceiptPayload struct {
@@ -35,6 +134,16 @@ type ReadReceiptPayload struct {
 	CircleID  string `json:"circleId"`
 }
 
+// ResumePayload is sent by a client after reconnecting, carrying the unix
+// timestamp (seconds) of the last message it saw in the circle, so the
+// server can replay whatever it missed instead of the client needing to
+// notice and paper over the gap itself. LastSeenAt of zero means "not
+// specified" and falls back to the persisted CircleReadCursor.
+type ResumePayload struct {
+	CircleID   string `json:"circleId"`
+	LastSeenAt int64  `json:"lastSeenAt,omitempty"`
+}
+
 type MessageResponse struct {
 	ID           string   `json:"id"`
 	CircleID     string   `json:"circleId"`
@@ -45,9 +154,15 @@ type MessageResponse struct {
 	ImageURL     *string  `json:"imageUrl"`
 	CreatedAt    string   `json:"createdAt"`
 	ReadBy       []string `json:"readBy"`
+	// Encrypted reports whether Content/ImageURL are opaque client-side
+	// ciphertext (the circle's Encrypted flag was set) rather than
+	// server-decryptable plaintext.
+	Encrypted bool    `json:"encrypted"`
+	Counter   *uint32 `json:"counter,omitempty"`
+	Epoch     *int    `json:"epoch,omitempty"`
 }
 
-func NewSocketServer(client *db.PrismaClient) (*SocketServer, error) {
+func NewSocketServer(client *db.PrismaClient, cipher *crypto.EnvelopeCipher) (*SocketServer, error) {
 	wsTransport := transportWebsocket.Default
 	wsTransport.CheckOrigin = func(r *http.Request) bool {
 		return true
@@ -59,7 +174,9 @@ func NewSocketServer(client *db.PrismaClient) (*SocketServer, error) {
 	}
 
 	server := socketio.NewServer(&engineio.Options{
-		Transports: []transport.Transport{pollingTransport, wsTransport},
+		Transports:   []transport.Transport{pollingTransport, wsTransport},
+		PingInterval: 30 * time.Second,
+		PingTimeout:  90 * time.Second,
 		RequestChecker: func(r *http.Request) (http.Header, error) {
 			headers := http.Header{}
 			origin := r.Header.Get("Origin")
@@ -75,6 +192,8 @@ func NewSocketServer(client *db.PrismaClient) (*SocketServer, error) {
 	ss := &SocketServer{
 		server: server,
 		client: client,
+		cipher: cipher,
+		e2ee:   services.NewE2EEService(client),
 	}
 
 	ss.setupHandlers()
@@ -141,21 +260,67 @@ func (ss *SocketServer) setupHandlers() {
 
 		ensureRoomMembership(s, payload.CircleID)
 
+		circle, err := ss.client.Circle.FindUnique(
+			db.Circle.ID.Equals(payload.CircleID),
+		).Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to load circle %s: %v", payload.CircleID, err)
+			s.Emit("message_error", "Server error")
+			return
+		}
+		circleEncrypted := circle.Encrypted
+
+		if circleEncrypted {
+			if payload.Epoch == nil {
+				log.Printf("Missing epoch on encrypted-circle message from user %s", userID)
+				s.Emit("message_error", "Missing key epoch for encrypted circle")
+				return
+			}
+			if err := ss.e2ee.ValidateEnvelope(ctx, payload.CircleID, *payload.Epoch, userID); err != nil {
+				log.Printf("Rejecting message from user %s in circle %s: %v", userID, payload.CircleID, err)
+				s.Emit("message_error", "Your key session is out of date - rekey and try again")
+				return
+			}
+		}
+
+		// When the circle is end-to-end encrypted, Content already is the
+		// client's XChaCha20-Poly1305 ciphertext - the server stores it
+		// opaquely instead of wrapping it in its own at-rest encryption,
+		// since it never has the chain key needed to produce that anyway.
+		storedContent := payload.Content
+		if !circleEncrypted {
+			encryptedContent, err := ss.cipher.EncryptForUser(ctx, userID, payload.Content)
+			if err != nil {
+				log.Printf("Failed to encrypt message content for user %s: %v", userID, err)
+				s.Emit("message_error", "Failed to send message")
+				return
+			}
+			storedContent = crypto.Encode(encryptedContent)
+		}
+
+		var counter *int
+		if payload.Counter != nil {
+			c := int(*payload.Counter)
+			counter = &c
+		}
+
 		// Create message in database
+		optionalParams := []db.MessageSetParam{
+			db.Message.Counter.SetIfPresent(counter),
+			db.Message.Epoch.SetIfPresent(payload.Epoch),
+		}
+		if payload.ImageID != "" {
+			optionalParams = append(optionalParams, db.Message.Image.Link(db.MessageImage.ID.Equals(payload.ImageID)))
+		}
+
 		message, err := ss.client.Message.CreateOne(
 			db.Message.Circle.Link(db.Circle.ID.Equals(payload.CircleID)),
 			db.Message.Sender.Link(db.User.ID.Equals(userID)),
-			db.Message.Content.Set(payload.Content),
-			db.Message.ImageURL.SetIfPresent(
-				func() *string {
-					if payload.ImageURL != "" {
-						return &payload.ImageURL
-					}
-					return nil
-				}(),
-			),
+			db.Message.Content.Set(storedContent),
+			optionalParams...,
 		).With(
 			db.Message.Sender.Fetch(),
+			db.Message.Image.Fetch(),
 		).Exec(ctx)
 
 		if err != nil {
@@ -173,8 +338,9 @@ func (ss *SocketServer) setupHandlers() {
 		}
 
 		var imageURL *string
-		if img, ok := message.ImageURL(); ok {
-			imageURL = &img
+		if _, ok := message.Image(); ok {
+			url := fmt.Sprintf("/api/messages/image/%s", message.ID)
+			imageURL = &url
 		}
 
 
@@ -196,16 +362,27 @@ func (ss *SocketServer) setupHandlers() {
 			SenderID:     sender.ID,
 			SenderName:   sender.FullName,
 			SenderAvatar: profilePic,
-			Content:      message.Content,
+			Content:      payload.Content,
 			ImageURL:     imageURL,
 			CreatedAt:    message.CreatedAt.Format(time.RFC3339),
 			ReadBy:       []string{userID}, // Sender has read their own message
+			Encrypted:    circleEncrypted,
+			Counter:      payload.Counter,
+			Epoch:        payload.Epoch,
 		}
 
 		log.Printf("Message %s created by user %s in circle %s", message.ID, userID, payload.CircleID)
 
 		// Broadcast to all users in the circle
 		ss.server.BroadcastToRoom("/", payload.CircleID, "new_message", response)
+
+		if ss.dispatcher != nil {
+			ss.dispatcher.Fire(ctx, webhooks.EventMessageCreated, sender.ID)
+		}
+
+		if ss.bridge != nil && !circleEncrypted {
+			ss.bridge.OnNewMessage(ctx, payload.CircleID, sender.ID, sender.FullName, payload.Content)
+		}
 	})
 
 	ss.server.OnEvent("/", "mark_read", func(s socketio.Conn, data string) {
@@ -282,6 +459,12 @@ func (ss *SocketServer) setupHandlers() {
 			"userId":    userID,
 			"readBy":    readByUserIDs,
 		})
+
+		if ss.bridge != nil {
+			ss.bridge.OnMessageRead(payload.CircleID, payload.MessageID, userID)
+		}
+
+		ss.touchReadCursor(ctx, userID, payload.CircleID)
 	})
 
 	ss.server.OnEvent("/", "typing_start", func(s socketio.Conn, circleID string) {
@@ -299,6 +482,9 @@ func (ss *SocketServer) setupHandlers() {
 					"userId": userID,
 					"typing": true,
 				})
+				if ss.bridge != nil {
+					ss.bridge.OnTyping(circleID, userID, true)
+				}
 				break
 			}
 		}
@@ -319,11 +505,222 @@ func (ss *SocketServer) setupHandlers() {
 					"userId": userID,
 					"typing": false,
 				})
+				if ss.bridge != nil {
+					ss.bridge.OnTyping(circleID, userID, false)
+				}
 				break
 			}
 		}
 	})
 
+	ss.server.OnEvent("/", "key_bundle_upload", func(s socketio.Conn, data string) {
+		ctx := context.Background()
+		userID, ok := getUserIDFromConn(s)
+		if !ok {
+			log.Printf("Unauthorized key bundle upload attempt from socket %s", s.ID())
+			return
+		}
+
+		var payload KeyBundleUploadPayload
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			log.Printf("Invalid key bundle upload payload: %v", err)
+			return
+		}
+
+		if payload.CircleID == "" || payload.IdentityPublicKey == "" {
+			return
+		}
+
+		allowed, err := ss.userHasCircleAccess(ctx, payload.CircleID, userID)
+		if err != nil || !allowed {
+			log.Printf("User %s not authorized to publish keys for circle %s", userID, payload.CircleID)
+			return
+		}
+
+		if _, err := ss.client.UserKey.UpsertOne(
+			db.UserKey.UserID.Equals(userID),
+		).Create(
+			db.UserKey.User.Link(db.User.ID.Equals(userID)),
+			db.UserKey.IdentityPublicKey.Set(payload.IdentityPublicKey),
+		).Update(
+			db.UserKey.IdentityPublicKey.Set(payload.IdentityPublicKey),
+		).Exec(ctx); err != nil {
+			log.Printf("Failed to store identity key for user %s: %v", userID, err)
+			return
+		}
+
+		for _, dist := range payload.Distributions {
+			if _, err := ss.client.SenderKeyDistribution.CreateOne(
+				db.SenderKeyDistribution.Circle.Link(db.Circle.ID.Equals(payload.CircleID)),
+				db.SenderKeyDistribution.FromUser.Link(db.User.ID.Equals(userID)),
+				db.SenderKeyDistribution.ToUser.Link(db.User.ID.Equals(dist.ToUserID)),
+				db.SenderKeyDistribution.SealedChainKey.Set(dist.SealedChainKey),
+				db.SenderKeyDistribution.Epoch.Set(dist.Epoch),
+			).Exec(ctx); err != nil {
+				log.Printf("Failed to store sender key distribution from %s to %s: %v", userID, dist.ToUserID, err)
+			}
+		}
+
+		log.Printf("User %s published key bundle for circle %s", userID, payload.CircleID)
+	})
+
+	ss.server.OnEvent("/", "key_bundle_request", func(s socketio.Conn, circleID string) {
+		ctx := context.Background()
+		userID, ok := getUserIDFromConn(s)
+		if !ok {
+			log.Printf("Unauthorized key bundle request from socket %s", s.ID())
+			return
+		}
+
+		allowed, err := ss.userHasCircleAccess(ctx, circleID, userID)
+		if err != nil || !allowed {
+			log.Printf("User %s not authorized to request keys for circle %s", userID, circleID)
+			return
+		}
+
+		circle, err := ss.client.Circle.FindUnique(
+			db.Circle.ID.Equals(circleID),
+		).Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to load circle %s for key bundle request: %v", circleID, err)
+			return
+		}
+
+		members, err := ss.client.CircleMembership.FindMany(
+			db.CircleMembership.CircleID.Equals(circleID),
+		).With(
+			db.CircleMembership.User.Fetch().With(
+				db.User.UserKey.Fetch(),
+			),
+		).Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to load members for circle %s: %v", circleID, err)
+			return
+		}
+
+		identityKeys := make([]IdentityKeyEntry, 0, len(members))
+		for _, member := range members {
+			user := member.User()
+			key, ok := user.UserKey()
+			if !ok {
+				continue
+			}
+			identityKeys = append(identityKeys, IdentityKeyEntry{
+				UserID:            user.ID,
+				IdentityPublicKey: key.IdentityPublicKey,
+			})
+		}
+
+		distributions, err := ss.client.SenderKeyDistribution.FindMany(
+			db.SenderKeyDistribution.CircleID.Equals(circleID),
+			db.SenderKeyDistribution.ToUserID.Equals(userID),
+			db.SenderKeyDistribution.Epoch.Equals(circle.Epoch),
+		).Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to load sender key distributions for circle %s: %v", circleID, err)
+			return
+		}
+
+		distributionsOut := make([]SenderKeyDistributionOut, len(distributions))
+		for i, dist := range distributions {
+			distributionsOut[i] = SenderKeyDistributionOut{
+				FromUserID:     dist.FromUserID,
+				SealedChainKey: dist.SealedChainKey,
+				Epoch:          dist.Epoch,
+			}
+		}
+
+		s.Emit("key_bundle_response", KeyBundlePayload{
+			CircleID:      circleID,
+			Epoch:         circle.Epoch,
+			IdentityKeys:  identityKeys,
+			Distributions: distributionsOut,
+		})
+	})
+
+	ss.server.OnEvent("/", "rekey_circle", func(s socketio.Conn, circleID string) {
+		ctx := context.Background()
+		userID, ok := getUserIDFromConn(s)
+		if !ok {
+			log.Printf("Unauthorized rekey attempt from socket %s", s.ID())
+			return
+		}
+
+		circle, err := ss.client.Circle.FindUnique(
+			db.Circle.ID.Equals(circleID),
+		).Exec(ctx)
+		if err != nil {
+			log.Printf("Failed to load circle %s for rekey: %v", circleID, err)
+			return
+		}
+
+		if circle.ModeratorID != userID {
+			log.Printf("User %s is not the moderator of circle %s, refusing rekey", userID, circleID)
+			return
+		}
+
+		// Drop every distribution from the old epoch so a removed member's
+		// last-known chain key can't derive any future message key; members
+		// still in the circle re-publish fresh distributions for the new
+		// epoch via key_bundle_upload once they see rekey_required.
+		if _, err := ss.client.SenderKeyDistribution.FindMany(
+			db.SenderKeyDistribution.CircleID.Equals(circleID),
+			db.SenderKeyDistribution.Epoch.Equals(circle.Epoch),
+		).Delete().Exec(ctx); err != nil {
+			log.Printf("Failed to clear old key distributions for circle %s: %v", circleID, err)
+			return
+		}
+
+		newEpoch := circle.Epoch + 1
+		if _, err := ss.client.Circle.FindUnique(
+			db.Circle.ID.Equals(circleID),
+		).Update(
+			db.Circle.Epoch.Set(newEpoch),
+		).Exec(ctx); err != nil {
+			log.Printf("Failed to advance epoch for circle %s: %v", circleID, err)
+			return
+		}
+
+		if err := ss.e2ee.RecomputeSession(ctx, circleID); err != nil {
+			log.Printf("Failed to recompute E2EE session for circle %s: %v", circleID, err)
+		}
+
+		log.Printf("Moderator %s rekeyed circle %s to epoch %d", userID, circleID, newEpoch)
+
+		ss.server.BroadcastToRoom("/", circleID, "rekey_required", map[string]interface{}{
+			"circleId": circleID,
+			"epoch":    newEpoch,
+		})
+	})
+
+	ss.server.OnEvent("/", "resume", func(s socketio.Conn, data string) {
+		ctx := context.Background()
+		userID, ok := getUserIDFromConn(s)
+		if !ok {
+			log.Printf("Unauthorized resume attempt from socket %s", s.ID())
+			return
+		}
+
+		var payload ResumePayload
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			log.Printf("Invalid resume payload: %v", err)
+			return
+		}
+
+		if payload.CircleID == "" {
+			return
+		}
+
+		allowed, err := ss.userHasCircleAccess(ctx, payload.CircleID, userID)
+		if err != nil || !allowed {
+			log.Printf("User %s not authorized to resume circle %s", userID, payload.CircleID)
+			return
+		}
+
+		ensureRoomMembership(s, payload.CircleID)
+		ss.replayMissedMessages(ctx, s, userID, payload.CircleID, payload.LastSeenAt)
+	})
+
 	ss.server.OnDisconnect("/", func(s socketio.Conn, reason string) {
 		log.Printf("Socket disconnected: %s, reason: %s", s.ID(), reason)
 	})
@@ -355,6 +752,16 @@ func (ss *SocketServer) authenticateConnection(s socketio.Conn, token string) bo
 	ctxMap["userID"] = userID
 	s.SetContext(ctxMap)
 
+	// Every connection also joins a personal room keyed by its own user
+	// ID, so server-side code (e.g. the check-in notifier) can push an
+	// in-app notification to a specific user without tracking socket IDs.
+	s.Join(userID)
+
+	if claims.Role == string(db.UserRoleModerator) {
+		s.Join(moderatorsRoom)
+		log.Printf("Moderator %s joined moderators room", userID)
+	}
+
 	membership, err := ss.client.CircleMembership.FindFirst(
 		db.CircleMembership.UserID.Equals(userID),
 	).With(
@@ -386,6 +793,11 @@ func (ss *SocketServer) authenticateConnection(s socketio.Conn, token string) bo
 			"memberCount": len(memberships),
 		})
 
+		// Auto-rejoin already happened via s.Join above; replay whatever
+		// the client missed while disconnected using its persisted
+		// read cursor, bounded to resumeBackfillWindow/resumeBackfillLimit.
+		ss.replayMissedMessages(ctx, s, userID, circleID, 0)
+
 		return true
 	}
 
@@ -420,13 +832,277 @@ func (ss *SocketServer) authenticateConnection(s socketio.Conn, token string) bo
 		"memberCount": len(moderatorCircle.Memberships()),
 	})
 
+	ss.replayMissedMessages(ctx, s, userID, circleID, 0)
+
 	return true
 }
 
+// SetMatrixBridge attaches an optional Matrix bridge so circle activity
+// also gets mirrored onto Matrix. Called at most once, during startup.
+func (ss *SocketServer) SetMatrixBridge(bridge MatrixBridge) {
+	ss.bridge = bridge
+}
+
+// SetWebhookDispatcher attaches the webhook dispatcher so socket-originated
+// events (message.created) can be fired at subscribers the same way
+// handler-originated ones already are. Called at most once, during
+// startup, since the dispatcher itself is constructed after the socket
+// server.
+func (ss *SocketServer) SetWebhookDispatcher(dispatcher *webhooks.Dispatcher) {
+	ss.dispatcher = dispatcher
+}
+
 func (ss *SocketServer) GetServer() *socketio.Server {
 	return ss.server
 }
 
+// BroadcastCrisisAlert notifies every connected moderator that a user has
+// been flagged as critical risk during onboarding, so on-call staff see it
+// immediately instead of polling.
+func (ss *SocketServer) BroadcastCrisisAlert(userID string, phq2Total, gad2Total int, timestamp time.Time) {
+	ss.server.BroadcastToRoom("/", moderatorsRoom, "crisis_alert", CrisisAlertPayload{
+		UserID:    userID,
+		Phq2Total: phq2Total,
+		Gad2Total: gad2Total,
+		Timestamp: timestamp.Unix(),
+	})
+}
+
+// ExtendedCrisisAlertPayload is broadcast to the moderators room when a
+// user's full PHQ-9/GAD-7 screening crosses IsCriticalRiskExtended's
+// thresholds, including the self-harm item answer that can trigger it
+// alone regardless of the totals.
+type ExtendedCrisisAlertPayload struct {
+	UserID       string `json:"userId"`
+	Phq9Total    int    `json:"phq9Total"`
+	Gad7Total    int    `json:"gad7Total"`
+	SelfHarmItem int    `json:"selfHarmItem"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+// BroadcastExtendedCrisisAlert mirrors BroadcastCrisisAlert for the
+// full PHQ-9/GAD-7 instrument's escalation path.
+func (ss *SocketServer) BroadcastExtendedCrisisAlert(userID string, phq9Total, gad7Total, selfHarmItem int, timestamp time.Time) {
+	ss.server.BroadcastToRoom("/", moderatorsRoom, "crisis_alert_extended", ExtendedCrisisAlertPayload{
+		UserID:       userID,
+		Phq9Total:    phq9Total,
+		Gad7Total:    gad7Total,
+		SelfHarmItem: selfHarmItem,
+		Timestamp:    timestamp.Unix(),
+	})
+}
+
+// Metrics returns a snapshot of the socket server's live connection count,
+// intended to be scraped by a monitoring agent.
+func (ss *SocketServer) Metrics() Metrics {
+	return Metrics{ActiveConnections: ss.server.Count()}
+}
+
+// CheckInPayload is delivered to a user's personal room by the check-in
+// notifier when they're connected and have opted into in-app delivery.
+type CheckInPayload struct {
+	Message        string `json:"message"`
+	CrisisDeepLink string `json:"crisisDeepLink"`
+}
+
+// BroadcastCheckIn delivers an in-app wellness check-in to userID's
+// personal room, a no-op if they aren't currently connected.
+func (ss *SocketServer) BroadcastCheckIn(userID, message, crisisDeepLink string) {
+	ss.server.BroadcastToRoom("/", userID, "check_in", CheckInPayload{
+		Message:        message,
+		CrisisDeepLink: crisisDeepLink,
+	})
+}
+
+// NotificationToastPayload is delivered to a user's personal room by
+// notifier.InAppChannel for any event the NotificationPlanner routes
+// in-app.
+type NotificationToastPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// BroadcastNotification delivers an in-app toast to userID's personal
+// room, a no-op if they aren't currently connected.
+func (ss *SocketServer) BroadcastNotification(userID, title, body string) {
+	ss.server.BroadcastToRoom("/", userID, "notification", NotificationToastPayload{
+		Title: title,
+		Body:  body,
+	})
+}
+
+// replayMissedMessages emits every new_message event a client missed for
+// circleID while disconnected, then a single resume_complete with the new
+// high-watermark. This mirrors the IRC/XMPP autorejoin-on-reconnect
+// pattern: lastSeenAt (unix seconds) is the client's own cursor if it has
+// one, otherwise the persisted CircleReadCursor is used, and either way
+// the replay is bounded to resumeBackfillWindow/resumeBackfillLimit so a
+// long absence can't trigger an unbounded backfill.
+func (ss *SocketServer) replayMissedMessages(ctx context.Context, s socketio.Conn, userID, circleID string, lastSeenAt int64) {
+	earliestAllowed := time.Now().UTC().Add(-resumeBackfillWindow)
+
+	since := earliestAllowed
+	if lastSeenAt > 0 {
+		if requested := time.Unix(lastSeenAt, 0).UTC(); requested.After(earliestAllowed) {
+			since = requested
+		}
+	} else if cursor, err := ss.client.CircleReadCursor.FindFirst(
+		db.CircleReadCursor.UserID.Equals(userID),
+		db.CircleReadCursor.CircleID.Equals(circleID),
+	).Exec(ctx); err == nil && cursor != nil {
+		if persisted := time.Time(cursor.LastSeenAt).UTC(); persisted.After(earliestAllowed) {
+			since = persisted
+		}
+	}
+
+	circle, err := ss.client.Circle.FindUnique(
+		db.Circle.ID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to load circle %s for resume: %v", circleID, err)
+		return
+	}
+
+	messages, err := ss.client.Message.FindMany(
+		db.Message.CircleID.Equals(circleID),
+		db.Message.CreatedAt.Gt(db.DateTime(since)),
+	).With(
+		db.Message.Sender.Fetch(),
+		db.Message.ReadReceipts.Fetch(),
+		db.Message.Image.Fetch(),
+	).OrderBy(
+		db.Message.CreatedAt.Order(db.DESC),
+	).Take(resumeBackfillLimit).Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to replay missed messages for user %s in circle %s: %v", userID, circleID, err)
+		return
+	}
+
+	// Ordering DESC before Take keeps the newest resumeBackfillLimit
+	// messages when a user missed more than that many - ASC+Take would
+	// instead keep the oldest, withholding the most recent messages
+	// until a later resume. Reverse back to chronological order before
+	// replaying them.
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	highWatermark := since
+	for _, message := range messages {
+		sender := message.Sender()
+
+		var profilePic *string
+		if pic, ok := sender.ProfilePicture(); ok {
+			profilePic = &pic
+		}
+
+		var imageURL *string
+		if _, ok := message.Image(); ok {
+			url := fmt.Sprintf("/api/messages/image/%s", message.ID)
+			imageURL = &url
+		}
+
+		content := message.Content
+		if !circle.Encrypted {
+			decrypted, err := ss.decryptStoredContent(ctx, sender.ID, message.Content)
+			if err != nil {
+				log.Printf("Failed to decrypt replayed message %s: %v", message.ID, err)
+				decrypted = ""
+			}
+			content = decrypted
+		}
+
+		var counter *uint32
+		if c, ok := message.Counter(); ok {
+			u := uint32(c)
+			counter = &u
+		}
+
+		var epoch *int
+		if e, ok := message.Epoch(); ok {
+			epoch = &e
+		}
+
+		readReceipts := message.ReadReceipts()
+		readBy := make([]string, len(readReceipts))
+		for i, receipt := range readReceipts {
+			readBy[i] = receipt.UserID
+		}
+
+		s.Emit("new_message", MessageResponse{
+			ID:           message.ID,
+			CircleID:     message.CircleID,
+			SenderID:     sender.ID,
+			SenderName:   sender.FullName,
+			SenderAvatar: profilePic,
+			Content:      content,
+			ImageURL:     imageURL,
+			CreatedAt:    message.CreatedAt.Format(time.RFC3339),
+			ReadBy:       readBy,
+			Encrypted:    circle.Encrypted,
+			Counter:      counter,
+			Epoch:        epoch,
+		})
+
+		if message.CreatedAt.After(highWatermark) {
+			highWatermark = message.CreatedAt
+		}
+	}
+
+	log.Printf("Replayed %d missed message(s) to user %s in circle %s", len(messages), userID, circleID)
+
+	s.Emit("resume_complete", map[string]interface{}{
+		"circleId":    circleID,
+		"lastSeenAt":  highWatermark.Unix(),
+		"replayCount": len(messages),
+	})
+}
+
+// decryptStoredContent reverses the at-rest encryption applied to
+// Message.Content for non-end-to-end-encrypted circles.
+func (ss *SocketServer) decryptStoredContent(ctx context.Context, senderID, encoded string) (string, error) {
+	ciphertext, err := crypto.Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	return ss.cipher.DecryptForUser(ctx, senderID, ciphertext)
+}
+
+// touchReadCursor persists userID's last-read position in circleID, so a
+// reconnect long after their last mark_read still gets a bounded replay
+// instead of either silence or an unbounded one.
+func (ss *SocketServer) touchReadCursor(ctx context.Context, userID, circleID string) {
+	now := db.DateTime(time.Now().UTC())
+
+	existing, err := ss.client.CircleReadCursor.FindFirst(
+		db.CircleReadCursor.UserID.Equals(userID),
+		db.CircleReadCursor.CircleID.Equals(circleID),
+	).Exec(ctx)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Failed to load read cursor for user %s in circle %s: %v", userID, circleID, err)
+		return
+	}
+
+	if existing != nil {
+		if _, err := ss.client.CircleReadCursor.FindUnique(
+			db.CircleReadCursor.ID.Equals(existing.ID),
+		).Update(
+			db.CircleReadCursor.LastSeenAt.Set(now),
+		).Exec(ctx); err != nil {
+			log.Printf("Failed to update read cursor for user %s in circle %s: %v", userID, circleID, err)
+		}
+		return
+	}
+
+	if _, err := ss.client.CircleReadCursor.CreateOne(
+		db.CircleReadCursor.User.Link(db.User.ID.Equals(userID)),
+		db.CircleReadCursor.Circle.Link(db.Circle.ID.Equals(circleID)),
+		db.CircleReadCursor.LastSeenAt.Set(now),
+	).Exec(ctx); err != nil {
+		log.Printf("Failed to create read cursor for user %s in circle %s: %v", userID, circleID, err)
+	}
+}
+
 func (ss *SocketServer) userHasCircleAccess(ctx context.Context, circleID, userID string) (bool, error) {
 	membership, err := ss.client.CircleMembership.FindFirst(
 		db.CircleMembership.UserID.Equals(userID),